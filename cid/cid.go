@@ -1,11 +1,10 @@
 package cid
 
 import (
-	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
-	"io/ioutil"
 
 	"github.com/ipfs/go-cid"
 	mh "github.com/multiformats/go-multihash"
@@ -23,16 +22,6 @@ type ContentID struct {
 	cid *cid.Cid
 }
 
-// Format is the kind of CID to generate.
-type Format int
-
-// Format options
-const (
-	SHA1 Format = iota
-	CidV0
-	CidV1
-)
-
 // Parse converts a string to a ContentID.
 func Parse(s string) (ContentID, error) {
 	if len(s) < sha1Length {
@@ -48,34 +37,17 @@ func Parse(s string) (ContentID, error) {
 	return ContentID{cid: &decoded}, nil
 }
 
-// New calculates a ContentID from data, in the given format.
-func New(r io.Reader, fmt Format) (ContentID, error) {
-	var builder cid.Builder
-	switch fmt {
-	case SHA1:
-		hash, err := newSHA1(r)
-		if err != nil {
-			return ContentID{}, err
-		}
-		return ContentID{str: &hash}, nil
-	case CidV0:
-		builder = cid.V0Builder{}
-	case CidV1:
-		builder = cid.V1Builder{
-			Codec:  cid.Raw,
-			MhType: mh.SHA2_256,
-		}
-	}
-	// NOTE: is there any way to stream data in? Why isn't this a problem for IPFS?
-	data, err := ioutil.ReadAll(r)
+// New calculates a ContentID from data, in the given format. The data is
+// hashed as it's read, so memory use is constant regardless of its size.
+func New(r io.Reader, format Format) (ContentID, error) {
+	h, err := NewHasher(format)
 	if err != nil {
 		return ContentID{}, err
 	}
-	v1, err := builder.Sum(data)
-	if err != nil {
+	if _, err := io.Copy(h, r); err != nil {
 		return ContentID{}, err
 	}
-	return ContentID{cid: &v1}, nil
+	return h.Sum()
 }
 
 // NewLiteral constructs a ContentID whose value is literally the input. This
@@ -123,13 +95,62 @@ func (c ContentID) Hash() string {
 
 const sha1Length = 40
 
-// newSHA1 calcualtes the original Hash content id.
-func newSHA1(r io.Reader) (string, error) {
-	sha := sha1.New()
-	_, err := io.Copy(sha, r)
+// Format returns the format that the ContentID was calculated with. It
+// returns false if the format is not registered, for example a literal.
+func (c ContentID) Format() (Format, bool) {
+	if c.cid != nil {
+		prefix := c.cid.Prefix()
+		return findFormat(func(s FormatSpec) bool {
+			return !s.hex &&
+				s.Version == prefix.Version &&
+				s.Codec == prefix.Codec &&
+				s.MhType == prefix.MhType
+		})
+	}
+	if c.str != nil && len(*c.str) == sha1Length {
+		if _, err := hex.DecodeString(*c.str); err == nil {
+			return SHA1, true
+		}
+	}
+	return 0, false
+}
+
+// Hasher calculates a ContentID from data as it's written.
+type Hasher struct {
+	spec FormatSpec
+	hash hash.Hash
+}
+
+// NewHasher initializes a Hasher for the given format.
+func NewHasher(format Format) (*Hasher, error) {
+	spec, ok := lookupFormat(format)
+	if !ok {
+		return nil, fmt.Errorf("cid: unknown format %d", format)
+	}
+	return &Hasher{spec: spec, hash: spec.Hash()}, nil
+}
+
+// Write implements io.Writer. It never returns an error.
+func (h *Hasher) Write(p []byte) (int, error) {
+	return h.hash.Write(p)
+}
+
+// Sum returns the ContentID of the data written so far.
+func (h *Hasher) Sum() (ContentID, error) {
+	digest := h.hash.Sum(nil)
+	if h.spec.hex {
+		str := hex.EncodeToString(digest)
+		return ContentID{str: &str}, nil
+	}
+	mhash, err := mh.Encode(digest, h.spec.MhType)
 	if err != nil {
-		return "", err
+		return ContentID{}, err
+	}
+	var c cid.Cid
+	if h.spec.Version == 0 {
+		c = cid.NewCidV0(mhash)
+	} else {
+		c = cid.NewCidV1(h.spec.Codec, mhash)
 	}
-	shaStr := hex.EncodeToString(sha.Sum(nil))
-	return shaStr, nil
+	return ContentID{cid: &c}, nil
 }
@@ -2,8 +2,13 @@ package cid
 
 import (
 	"bytes"
+	"errors"
 	"reflect"
 	"testing"
+	"testing/iotest"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
 )
 
 func TestParse(t *testing.T) {
@@ -79,6 +84,52 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewStreaming(t *testing.T) {
+	data := bytes.Repeat([]byte("streaming data "), 1<<16)
+	tests := []struct {
+		desc    string
+		fmt     Format
+		builder cid.Builder
+	}{
+		{
+			desc:    "cidv0",
+			fmt:     CidV0,
+			builder: cid.V0Builder{},
+		},
+		{
+			desc:    "cidv1",
+			fmt:     CidV1,
+			builder: cid.V1Builder{Codec: cid.Raw, MhType: mh.SHA2_256},
+		},
+	}
+	for _, tt := range tests {
+		want, err := tt.builder.Sum(data)
+		if err != nil {
+			t.Fatalf("%q builder failed: %s", tt.desc, err)
+		}
+		got, err := New(iotest.HalfReader(bytes.NewReader(data)), tt.fmt)
+		if err != nil {
+			t.Fatalf("%q failed: %s", tt.desc, err)
+		}
+		if got, want := got.String(), want.String(); got != want {
+			t.Errorf("%q String() got %s want %s", tt.desc, got, want)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	readErr := errors.New("read failed")
+	for _, f := range []Format{SHA1, CidV0, CidV1} {
+		_, err := New(iotest.ErrReader(readErr), f)
+		if err != readErr {
+			t.Errorf("format %d got err %v want %v", f, err, readErr)
+		}
+	}
+	if _, err := New(bytes.NewBufferString("a"), Format(-1)); err == nil {
+		t.Errorf("unknown format must return an error")
+	}
+}
+
 func TestEquality(t *testing.T) {
 	build := func(str string, fmt Format) ContentID {
 		cid, err := New(bytes.NewBufferString(str), fmt)
@@ -162,3 +213,38 @@ func TestEquality(t *testing.T) {
 		}
 	}
 }
+
+func TestHasher(t *testing.T) {
+	for _, f := range []Format{SHA1, CidV0, CidV1, CidV1Blake2b256, CidV1SHA512} {
+		want, err := New(bytes.NewBufferString("testing 123"), f)
+		if err != nil {
+			t.Fatalf("%s New() failed: %s", f, err)
+		}
+		h, err := NewHasher(f)
+		if err != nil {
+			t.Fatalf("%s NewHasher() failed: %s", f, err)
+		}
+		h.Write([]byte("testing"))
+		h.Write([]byte(" 123"))
+		got, err := h.Sum()
+		if err != nil {
+			t.Fatalf("%s Sum() failed: %s", f, err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("%s Sum() got %s want %s", f, got, want)
+		}
+		format, ok := got.Format()
+		if !ok {
+			t.Errorf("%s Format() must be ok", f)
+		}
+		if format != f {
+			t.Errorf("%s Format() got %s", f, format)
+		}
+	}
+	if _, err := NewHasher(Format(-1)); err == nil {
+		t.Errorf("unknown format must return an error")
+	}
+	if _, ok := NewLiteral("abc").Format(); ok {
+		t.Errorf("literal Format() must not be ok")
+	}
+}
@@ -0,0 +1,189 @@
+package cid
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Format is the kind of CID to generate.
+type Format int
+
+// Format options
+const (
+	SHA1 Format = iota
+	CidV0
+	CidV1
+	CidV1Blake2b256
+	CidV1SHA512
+)
+
+// FormatSpec describes how a Format calculates its ContentID. All formats
+// other than SHA1 produce a CID wrapping a multihash of the data.
+type FormatSpec struct {
+
+	// Name is a short, unique, human readable name for the format. It may
+	// be used to choose a format from configuration.
+	Name string
+
+	// Hash returns a new hash.Hash that calculates the digest.
+	Hash func() hash.Hash
+
+	// MhType is the multihash code of the digest.
+	MhType uint64
+
+	// MhName is the multihash name of the digest. It's only required if
+	// MhType is not already known to go-multihash, in which case it's
+	// registered so that CIDs using it can be parsed.
+	MhName string
+
+	// Version is the CID version. Only CidV0 uses version 0, all
+	// registered formats must use version 1.
+	Version uint64
+
+	// Codec is the CID content type, normally cid.Raw.
+	Codec uint64
+
+	// hex means the ContentID is the hex encoded digest, used by SHA1.
+	hex bool
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[Format]FormatSpec{
+		// keep in constant order
+		SHA1: {
+			Name:   "sha1",
+			Hash:   sha1.New,
+			MhType: mh.SHA1,
+			hex:    true,
+		},
+		CidV0: {
+			Name:    "cidv0",
+			Hash:    sha256.New,
+			MhType:  mh.SHA2_256,
+			Version: 0,
+			Codec:   cid.DagProtobuf,
+		},
+		CidV1: {
+			Name:    "cidv1",
+			Hash:    sha256.New,
+			MhType:  mh.SHA2_256,
+			Version: 1,
+			Codec:   cid.Raw,
+		},
+		CidV1Blake2b256: {
+			Name:    "cidv1-blake2b-256",
+			Hash:    newBlake2b256,
+			MhType:  mh.BLAKE2B_MIN + 31,
+			Version: 1,
+			Codec:   cid.Raw,
+		},
+		CidV1SHA512: {
+			Name:    "cidv1-sha2-512",
+			Hash:    sha512.New,
+			MhType:  mh.SHA2_512,
+			Version: 1,
+			Codec:   cid.Raw,
+		},
+	}
+)
+
+// RegisterFormat makes a new Format available to New. It returns an error
+// if the format, its name, or its combination of Version, Codec and MhType
+// is already registered, or if the spec is incomplete. Applications should
+// choose Format values well above the ones defined here, for example
+// starting at 1000, to avoid colliding with formats added in the future.
+//
+// RegisterFormat must only be called from init. A new MhType is added to
+// go-multihash's global tables, which are read without synchronization.
+func RegisterFormat(format Format, spec FormatSpec) error {
+	if spec.Name == "" {
+		return fmt.Errorf("cid: format %d has no name", format)
+	}
+	if spec.Hash == nil {
+		return fmt.Errorf("cid: format %q has no hash", spec.Name)
+	}
+	if spec.Version != 1 {
+		return fmt.Errorf("cid: format %q must be CID version 1", spec.Name)
+	}
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	if _, ok := formats[format]; ok {
+		return fmt.Errorf("cid: format %d is already registered", format)
+	}
+	for _, s := range formats {
+		if s.Name == spec.Name {
+			return fmt.Errorf("cid: format %q is already registered", spec.Name)
+		}
+		if !s.hex && s.Version == spec.Version && s.Codec == spec.Codec && s.MhType == spec.MhType {
+			return fmt.Errorf("cid: format %q has the same CID prefix as %q", spec.Name, s.Name)
+		}
+	}
+	if !mh.ValidCode(spec.MhType) {
+		if spec.MhName == "" {
+			return fmt.Errorf("cid: format %q has unknown multihash code %#x and no name", spec.Name, spec.MhType)
+		}
+		mh.Codes[spec.MhType] = spec.MhName
+		mh.Names[spec.MhName] = spec.MhType
+		mh.DefaultLengths[spec.MhType] = spec.Hash().Size()
+	}
+	formats[format] = spec
+	return nil
+}
+
+// ParseFormat returns the Format with the given name.
+func ParseFormat(name string) (Format, error) {
+	f, ok := findFormat(func(s FormatSpec) bool {
+		return s.Name == name
+	})
+	if !ok {
+		return 0, fmt.Errorf("cid: unknown format %q", name)
+	}
+	return f, nil
+}
+
+// Ok returns true if the format is registered.
+func (f Format) Ok() bool {
+	_, ok := lookupFormat(f)
+	return ok
+}
+
+func (f Format) String() string {
+	if spec, ok := lookupFormat(f); ok {
+		return spec.Name
+	}
+	return fmt.Sprintf("format(%d)", int(f))
+}
+
+func lookupFormat(f Format) (FormatSpec, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	spec, ok := formats[f]
+	return spec, ok
+}
+
+// findFormat returns the first registered format that matches.
+func findFormat(match func(FormatSpec) bool) (Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for f, s := range formats {
+		if match(s) {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+func newBlake2b256() hash.Hash {
+	// New256 only errors if given an invalid key.
+	h, _ := blake2b.New256(nil)
+	return h
+}
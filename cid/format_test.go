@@ -0,0 +1,143 @@
+package cid
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestNewFormats(t *testing.T) {
+	tests := []struct {
+		desc   string
+		fmt    Format
+		mhType uint64
+	}{
+		{
+			desc:   "blake2b-256",
+			fmt:    CidV1Blake2b256,
+			mhType: mh.BLAKE2B_MIN + 31,
+		},
+		{
+			desc:   "sha2-512",
+			fmt:    CidV1SHA512,
+			mhType: mh.SHA2_512,
+		},
+	}
+	for _, tt := range tests {
+		c, err := New(bytes.NewBufferString("testing 123"), tt.fmt)
+		if err != nil {
+			t.Fatalf("%q failed: %s", tt.desc, err)
+		}
+		want, err := cid.V1Builder{Codec: cid.Raw, MhType: tt.mhType}.Sum([]byte("testing 123"))
+		if err != nil {
+			t.Fatalf("%q builder failed: %s", tt.desc, err)
+		}
+		if got, want := c.String(), want.String(); got != want {
+			t.Errorf("%q String() got %s want %s", tt.desc, got, want)
+		}
+		parsed, err := Parse(c.String())
+		if err != nil {
+			t.Fatalf("%q Parse() failed: %s", tt.desc, err)
+		}
+		if !parsed.Equal(c) {
+			t.Errorf("%q Parse() got %s want %s", tt.desc, parsed, c)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []Format{SHA1, CidV0, CidV1, CidV1Blake2b256, CidV1SHA512} {
+		got, err := ParseFormat(f.String())
+		if err != nil {
+			t.Fatalf("%s failed: %s", f, err)
+		}
+		if got != f {
+			t.Errorf("ParseFormat(%q) got %d want %d", f, got, f)
+		}
+		if !f.Ok() {
+			t.Errorf("%s must be ok", f)
+		}
+	}
+	if _, err := ParseFormat("nope"); err == nil {
+		t.Errorf("unknown name must return an error")
+	}
+	if got, want := Format(-1).String(), "format(-1)"; got != want {
+		t.Errorf("String() got %s want %s", got, want)
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	const custom = Format(1000)
+	spec := FormatSpec{
+		Name:    "test-custom",
+		Hash:    sha256.New,
+		MhType:  0x1e,
+		MhName:  "test-blake3",
+		Version: 1,
+		Codec:   cid.Raw,
+	}
+	if err := RegisterFormat(custom, spec); err != nil {
+		t.Fatalf("RegisterFormat() failed: %s", err)
+	}
+	c, err := New(bytes.NewBufferString("testing 123"), custom)
+	if err != nil {
+		t.Fatalf("New() failed: %s", err)
+	}
+	parsed, err := Parse(c.String())
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+	if !parsed.Equal(c) {
+		t.Errorf("Parse() got %s want %s", parsed, c)
+	}
+
+	tests := []struct {
+		desc   string
+		format Format
+		spec   FormatSpec
+	}{
+		{
+			desc:   "duplicate format",
+			format: custom,
+			spec:   FormatSpec{Name: "other", Hash: sha256.New, MhType: mh.SHA2_256, Version: 1},
+		},
+		{
+			desc:   "duplicate name",
+			format: custom + 1,
+			spec:   FormatSpec{Name: "cidv1", Hash: sha256.New, MhType: mh.SHA2_256, Version: 1},
+		},
+		{
+			desc:   "duplicate prefix",
+			format: custom + 1,
+			spec:   FormatSpec{Name: "other", Hash: sha256.New, MhType: mh.SHA2_256, Version: 1, Codec: cid.Raw},
+		},
+		{
+			desc:   "no name",
+			format: custom + 1,
+			spec:   FormatSpec{Hash: sha256.New, MhType: mh.SHA2_256, Version: 1},
+		},
+		{
+			desc:   "no hash",
+			format: custom + 1,
+			spec:   FormatSpec{Name: "other", MhType: mh.SHA2_256, Version: 1},
+		},
+		{
+			desc:   "version 0",
+			format: custom + 1,
+			spec:   FormatSpec{Name: "other", Hash: sha256.New, MhType: mh.SHA2_256},
+		},
+		{
+			desc:   "unknown multihash",
+			format: custom + 1,
+			spec:   FormatSpec{Name: "other", Hash: sha256.New, MhType: 0x1f, Version: 1},
+		},
+	}
+	for _, tt := range tests {
+		if err := RegisterFormat(tt.format, tt.spec); err == nil {
+			t.Errorf("%q must return an error", tt.desc)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package data
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// bundlePrimary is the name of the primary content in a tar bundle written by
+// Encode.
+const bundlePrimary = "data"
+
+// Encode returns a writer that encodes data in the Stored encoding and writes
+// it to w. Data written should be the original content, so that its Hash is
+// unchanged by storage. Close must be called to flush the encoding; it does
+// not close w.
+//
+// A Tar encoding holds the content as the single file in a bundle. Since a tar
+// header needs the content's size it's spooled to a temporary file until
+// Close. Use BundleWriter to store additional files alongside it.
+func (s Stored) Encode(w io.Writer) io.WriteCloser {
+	info, ok := lookupEncoding(s.Encoding)
+	if !ok {
+		return errWriteCloser{fmt.Errorf("data: unknown encoding: %v", s.Encoding)}
+	}
+	if info.NewWriter == nil {
+		return nopWriteCloser{w}
+	}
+	return info.NewWriter(w)
+}
+
+// Decode returns a reader that decodes data read from r in the Stored
+// encoding, returning the original content. For a Tar encoding that's the
+// first file in the bundle. Close does not close r.
+func (s Stored) Decode(r io.Reader) io.ReadCloser {
+	info, ok := lookupEncoding(s.Encoding)
+	if !ok {
+		return errReadCloser{fmt.Errorf("data: unknown encoding: %v", s.Encoding)}
+	}
+	if info.NewReader == nil {
+		return ioutil.NopCloser(r)
+	}
+	rc, err := info.NewReader(r)
+	if err != nil {
+		return errReadCloser{err}
+	}
+	return rc
+}
+
+func newGZipWriter(w io.Writer) io.WriteCloser {
+	return gzip.NewWriter(w)
+}
+
+func newGZipReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func newZstdWriter(w io.Writer) io.WriteCloser {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return errWriteCloser{err}
+	}
+	return zw
+}
+
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return zr.IOReadCloser(), nil
+}
+
+func newXZWriter(w io.Writer) io.WriteCloser {
+	xw, err := xz.NewWriter(w)
+	if err != nil {
+		return errWriteCloser{err}
+	}
+	return xw
+}
+
+func newXZReader(r io.Reader) (io.ReadCloser, error) {
+	xr, err := xz.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(xr), nil
+}
+
+func newTarWriter(w io.Writer) io.WriteCloser {
+	return &tarWriter{w: w}
+}
+
+func newTarReader(r io.Reader) (io.ReadCloser, error) {
+	br := NewBundleReader(r)
+	_, content, err := br.Next()
+	if err == io.EOF {
+		return nil, errors.New("data: tar bundle is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(content), nil
+}
+
+// tarWriter spools content to a temporary file, then writes it as a single
+// file bundle on Close.
+type tarWriter struct {
+	w    io.Writer
+	tmp  *os.File
+	size int64
+}
+
+func (t *tarWriter) Write(p []byte) (int, error) {
+	if t.tmp == nil {
+		tmp, err := ioutil.TempFile("", "structure-tar")
+		if err != nil {
+			return 0, err
+		}
+		t.tmp = tmp
+	}
+	n, err := t.tmp.Write(p)
+	t.size += int64(n)
+	return n, err
+}
+
+func (t *tarWriter) Close() error {
+	var r io.Reader = eofReader{}
+	if t.tmp != nil {
+		defer os.Remove(t.tmp.Name())
+		defer t.tmp.Close()
+		if _, err := t.tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r = t.tmp
+	}
+	bw := NewBundleWriter(t.w)
+	if err := bw.Add(bundlePrimary, t.size, r); err != nil {
+		return err
+	}
+	return bw.Close()
+}
+
+// BundleWriter writes multi-file content, such as a RAW file and its XMP
+// sidecar, as a tar bundle. The first file added is the primary content:
+// the one identified by the Hash and returned by Stored.Decode.
+type BundleWriter struct {
+	tw *tar.Writer
+}
+
+// NewBundleWriter initializes a BundleWriter that writes to w.
+func NewBundleWriter(w io.Writer) *BundleWriter {
+	return &BundleWriter{tar.NewWriter(w)}
+}
+
+// Add writes a file of size bytes, read from r, to the bundle.
+func (b *BundleWriter) Add(name string, size int64, r io.Reader) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		Typeflag: tar.TypeReg,
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(b.tw, r, size); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close finishes the bundle. It does not close the underlying writer.
+func (b *BundleWriter) Close() error {
+	return b.tw.Close()
+}
+
+// BundleReader reads the files of a tar bundle.
+type BundleReader struct {
+	tr *tar.Reader
+}
+
+// NewBundleReader initializes a BundleReader that reads from r.
+func NewBundleReader(r io.Reader) *BundleReader {
+	return &BundleReader{tar.NewReader(r)}
+}
+
+// Next advances to the next file in the bundle, returning its name and
+// content. The content is valid until the next call to Next. It returns
+// io.EOF at the end of the bundle.
+func (b *BundleReader) Next() (string, io.Reader, error) {
+	for {
+		hdr, err := b.tr.Next()
+		if err != nil {
+			return "", nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+			return hdr.Name, b.tr, nil
+		}
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+type errWriteCloser struct {
+	err error
+}
+
+func (e errWriteCloser) Write(p []byte) (int, error) { return 0, e.err }
+func (e errWriteCloser) Close() error                { return e.err }
+
+type errReadCloser struct {
+	err error
+}
+
+func (e errReadCloser) Read(p []byte) (int, error) { return 0, e.err }
+func (e errReadCloser) Close() error               { return e.err }
+
+type eofReader struct{}
+
+func (eofReader) Read(p []byte) (int, error) { return 0, io.EOF }
@@ -0,0 +1,170 @@
+package data
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestStoredEncodeDecode(t *testing.T) {
+	tests := []struct {
+		desc    string
+		stored  Stored
+		content string
+		native  bool
+	}{
+		{
+			desc:    "native",
+			stored:  Stored{Type: JPG},
+			content: "testing 123",
+			native:  true,
+		},
+		{
+			desc:    "gzip",
+			stored:  Stored{Type: TIF, Encoding: GZip},
+			content: "testing 123",
+		},
+		{
+			desc:    "zstd",
+			stored:  Stored{Type: TIF, Encoding: Zstd},
+			content: "testing 123",
+		},
+		{
+			desc:    "xz",
+			stored:  Stored{Type: PSD, Encoding: XZ},
+			content: "testing 123",
+		},
+		{
+			desc:    "tar",
+			stored:  Stored{Type: NEF, Encoding: Tar},
+			content: "testing 123",
+		},
+		{
+			desc:    "tar empty",
+			stored:  Stored{Type: NEF, Encoding: Tar},
+			content: "",
+		},
+	}
+	for _, tt := range tests {
+		content := tt.content
+		var buf bytes.Buffer
+		w := tt.stored.Encode(&buf)
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatalf("%q Encode() write failed: %s", tt.desc, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%q Encode() close failed: %s", tt.desc, err)
+		}
+		if got := buf.String() == content; got != tt.native {
+			t.Errorf("%q encoded data unchanged got %t want %t", tt.desc, got, tt.native)
+		}
+		want, err := NewHash(bytes.NewBufferString(content))
+		if err != nil {
+			t.Fatalf("%q NewHash() failed: %s", tt.desc, err)
+		}
+		r := tt.stored.Decode(&buf)
+		hash, err := NewHash(r)
+		if err != nil {
+			t.Fatalf("%q hash of Decode() failed: %s", tt.desc, err)
+		}
+		if err := r.Close(); err != nil {
+			t.Errorf("%q Decode() close failed: %s", tt.desc, err)
+		}
+		if !hash.Equal(want) {
+			t.Errorf("%q decoded hash got %s want %s", tt.desc, hash, want)
+		}
+	}
+}
+
+func TestStoredDecodeErrors(t *testing.T) {
+	tests := []struct {
+		desc   string
+		stored Stored
+		data   string
+	}{
+		{
+			desc:   "unknown encoding",
+			stored: Stored{Type: JPG, Encoding: Encoding("nope")},
+			data:   "testing 123",
+		},
+		{
+			desc:   "invalid gzip",
+			stored: Stored{Type: JPG, Encoding: GZip},
+			data:   "testing 123",
+		},
+		{
+			desc:   "invalid zstd",
+			stored: Stored{Type: JPG, Encoding: Zstd},
+			data:   "testing 123",
+		},
+		{
+			desc:   "invalid xz",
+			stored: Stored{Type: JPG, Encoding: XZ},
+			data:   "testing 123",
+		},
+		{
+			desc:   "empty tar",
+			stored: Stored{Type: JPG, Encoding: Tar},
+			data:   "",
+		},
+	}
+	for _, tt := range tests {
+		r := tt.stored.Decode(bytes.NewBufferString(tt.data))
+		if _, err := ioutil.ReadAll(r); err == nil {
+			t.Errorf("%q Decode() expected an error", tt.desc)
+		}
+	}
+	w := Stored{Type: JPG, Encoding: Encoding("nope")}.Encode(ioutil.Discard)
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Errorf("Encode() of unknown encoding expected an error")
+	}
+}
+
+func TestBundle(t *testing.T) {
+	files := []struct {
+		name string
+		data string
+	}{
+		{"img.nef", "raw data"},
+		{"img.xmp", "<xmp/>"},
+	}
+	var buf bytes.Buffer
+	bw := NewBundleWriter(&buf)
+	for _, f := range files {
+		if err := bw.Add(f.name, int64(len(f.data)), bytes.NewBufferString(f.data)); err != nil {
+			t.Fatalf("Add(%q) failed: %s", f.name, err)
+		}
+	}
+	if err := bw.Close(); err != nil {
+		t.Fatalf("Close() failed: %s", err)
+	}
+	encoded := buf.Bytes()
+
+	br := NewBundleReader(bytes.NewReader(encoded))
+	for _, f := range files {
+		name, r, err := br.Next()
+		if err != nil {
+			t.Fatalf("Next() failed: %s", err)
+		}
+		if name != f.name {
+			t.Errorf("Next() name got %q want %q", name, f.name)
+		}
+		data, _ := ioutil.ReadAll(r)
+		if got, want := string(data), f.data; got != want {
+			t.Errorf("Next() %q data got %q want %q", name, got, want)
+		}
+	}
+	if _, _, err := br.Next(); err != io.EOF {
+		t.Errorf("Next() at end got %v want io.EOF", err)
+	}
+
+	r := Stored{Type: NEF, Encoding: Tar}.Decode(bytes.NewReader(encoded))
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Decode() failed: %s", err)
+	}
+	if got, want := string(data), files[0].data; got != want {
+		t.Errorf("Decode() got %q want primary %q", got, want)
+	}
+}
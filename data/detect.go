@@ -0,0 +1,238 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+)
+
+// sniffLen is the number of leading bytes read to detect a type.
+const sniffLen = 4096
+
+// TypeMismatchError is returned when the type detected from content does not
+// match the type of its extension.
+type TypeMismatchError struct {
+	Ext     Type
+	Content Type
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("data: extension type %v does not match content type %v", e.Ext, e.Content)
+}
+
+// DetectType determines the type of data from its content by matching magic
+// numbers and container headers. It reads at most the first 4KB of r; if the
+// data is needed afterwards, read it through a bufio.Reader and pass that, or
+// rebuild it with io.MultiReader. ErrUnknownType is returned if no type
+// matches.
+func DetectType(r io.Reader) (Type, error) {
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return UnknownType, err
+	}
+	return detectType(header[:n])
+}
+
+// DetectFileType determines the type of a file from its content, and checks
+// it against the file's extension. If the content's type cannot be detected,
+// the extension's type is returned. If they disagree, the content's type is
+// returned along with a *TypeMismatchError.
+func DetectFileType(name string, r io.Reader) (Type, error) {
+	var extType Type
+	if s, err := ParseType(path.Ext(name)); err == nil {
+		extType = s.Type
+	}
+	t, err := DetectType(r)
+	switch {
+	case err == ErrUnknownType && extType != UnknownType:
+		return extType, nil
+	case err != nil:
+		return UnknownType, err
+	case extType != UnknownType && extType != t:
+		return t, &TypeMismatchError{Ext: extType, Content: t}
+	}
+	return t, nil
+}
+
+func detectType(header []byte) (Type, error) {
+	if t, ok := matchSignature(header); ok {
+		return t, nil
+	}
+	for _, s := range sniffers {
+		if t, ok := s(header); ok {
+			return t, nil
+		}
+	}
+	return UnknownType, ErrUnknownType
+}
+
+// sniffer returns the type of data that begins with header.
+type sniffer func(header []byte) (Type, bool)
+
+// sniffers detect types whose content can't be identified by a Signature
+// alone, such as container formats. They're checked in order, after all
+// signatures. More specific formats must come before the formats they're
+// built on.
+var sniffers = []sniffer{
+	sniffTIFF,
+	sniffRIFF,
+	sniffMTS,
+	sniffISOBMFF,
+	sniffMP3Frame,
+}
+
+// isoBrands maps the major brand of an ISO base media file (the "ftyp" box)
+// to a type. Brands not listed are MP4.
+var isoBrands = map[string]Type{
+	"heic": HEIC,
+	"heix": HEIC,
+	"heim": HEIC,
+	"heis": HEIC,
+	"hevc": HEIC,
+	"hevx": HEIC,
+	"mif1": HEIF,
+	"msf1": HEIF,
+	"avif": AVIF,
+	"avis": AVIF,
+	"crx ": CR3,
+	"qt  ": MOV,
+	"M4V ": M4V,
+	"M4VH": M4V,
+	"M4VP": M4V,
+	"M4A ": M4A,
+	"3gp4": ThreeGP,
+	"3gp5": ThreeGP,
+	"3gp6": ThreeGP,
+	"3ge6": ThreeGP,
+	"3gg6": ThreeGP,
+}
+
+// sniffRIFF matches AVI, WAVE and WebP files by their RIFF form type.
+func sniffRIFF(header []byte) (Type, bool) {
+	if len(header) < 12 || string(header[0:4]) != "RIFF" {
+		return UnknownType, false
+	}
+	switch string(header[8:12]) {
+	case "AVI ":
+		return AVI, true
+	case "WAVE":
+		return WAV, true
+	case "WEBP":
+		return WEBP, true
+	}
+	return UnknownType, false
+}
+
+// sniffISOBMFF matches MPEG-4, QuickTime, HEIF and CR3 files by their ftyp
+// box.
+func sniffISOBMFF(header []byte) (Type, bool) {
+	if len(header) < 12 || string(header[4:8]) != "ftyp" {
+		return UnknownType, false
+	}
+	if t, ok := isoBrands[string(header[8:12])]; ok {
+		return t, true
+	}
+	return MP4, true
+}
+
+// sniffMTS matches an MPEG transport stream with 192 byte packets, which
+// prefix each 188 byte packet with a 4 byte timecode.
+func sniffMTS(header []byte) (Type, bool) {
+	if len(header) < 196+1 {
+		return UnknownType, false
+	}
+	return MTS, header[4] == 0x47 && header[196] == 0x47
+}
+
+// sniffMP3Frame matches an MP3 without an ID3 tag by its frame sync and
+// MPEG audio layer III header bits.
+func sniffMP3Frame(header []byte) (Type, bool) {
+	if len(header) < 2 {
+		return UnknownType, false
+	}
+	return MP3, header[0] == 0xff && header[1]&0xe0 == 0xe0 && header[1]&0x06 == 0x02
+}
+
+// tiffMakes identifies TIFF based RAW formats by the camera's Make.
+var tiffMakes = []struct {
+	prefix string
+	typ    Type
+}{
+	{"NIKON", NEF},
+	{"SONY", ARW},
+	{"PENTAX", PEF},
+	{"RICOH", PEF},
+	{"SAMSUNG", SRW},
+}
+
+// TIFF tags used to identify TIFF based RAW formats.
+const (
+	tiffTagMake       = 0x010f
+	tiffTagDNGVersion = 0xc612
+)
+
+// sniffTIFF matches TIFF and the RAW formats that use a TIFF container. CR2
+// is identified by its header, DNG by the DNGVersion tag and others by the
+// Make tag in the first IFD.
+func sniffTIFF(header []byte) (Type, bool) {
+	if len(header) < 8 {
+		return UnknownType, false
+	}
+	var order binary.ByteOrder
+	switch string(header[0:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return UnknownType, false
+	}
+	if len(header) >= 11 && string(header[8:10]) == "CR" && header[10] == 2 {
+		return CR2, true
+	}
+	var (
+		dng bool
+		mk  []byte
+	)
+	ifd := int(order.Uint32(header[4:8]))
+	if ifd > 0 && ifd+2 <= len(header) {
+		count := int(order.Uint16(header[ifd : ifd+2]))
+		for i := 0; i < count; i++ {
+			entry := ifd + 2 + i*12
+			if entry+12 > len(header) {
+				break
+			}
+			switch order.Uint16(header[entry : entry+2]) {
+			case tiffTagDNGVersion:
+				dng = true
+			case tiffTagMake:
+				mk = bytes.ToUpper(tiffASCII(header, order, entry))
+			}
+		}
+	}
+	if dng {
+		return DNG, true
+	}
+	for _, m := range tiffMakes {
+		if bytes.HasPrefix(mk, []byte(m.prefix)) {
+			return m.typ, true
+		}
+	}
+	return TIF, true
+}
+
+// tiffASCII returns the value of an ASCII IFD entry, if it's within header.
+func tiffASCII(header []byte, order binary.ByteOrder, entry int) []byte {
+	n := int(order.Uint32(header[entry+4 : entry+8]))
+	start := entry + 8
+	if n > 4 {
+		start = int(order.Uint32(header[entry+8 : entry+12]))
+	}
+	if start < 0 || n < 0 || start+n > len(header) {
+		return nil
+	}
+	return header[start : start+n]
+}
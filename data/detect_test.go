@@ -0,0 +1,332 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"testing/iotest"
+)
+
+// tiffHeader builds a minimal TIFF header with one IFD containing a Make tag
+// and optionally the DNGVersion tag.
+func tiffHeader(order binary.ByteOrder, mk string, dng bool) []byte {
+	var buf bytes.Buffer
+	if order == binary.LittleEndian {
+		buf.WriteString("II*\x00")
+	} else {
+		buf.WriteString("MM\x00*")
+	}
+	binary.Write(&buf, order, uint32(8))
+	count := uint16(1)
+	if dng {
+		count++
+	}
+	binary.Write(&buf, order, count)
+	valueOffset := uint32(8 + 2 + 12*int(count) + 4)
+	binary.Write(&buf, order, uint16(tiffTagMake))
+	binary.Write(&buf, order, uint16(2))
+	binary.Write(&buf, order, uint32(len(mk)+1))
+	binary.Write(&buf, order, valueOffset)
+	if dng {
+		binary.Write(&buf, order, uint16(tiffTagDNGVersion))
+		binary.Write(&buf, order, uint16(1))
+		binary.Write(&buf, order, uint32(4))
+		buf.Write([]byte{1, 4, 0, 0})
+	}
+	binary.Write(&buf, order, uint32(0))
+	buf.WriteString(mk)
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
+
+func TestDetectType(t *testing.T) {
+	tests := []struct {
+		desc    string
+		data    []byte
+		want    Type
+		wantErr error
+	}{
+		{
+			desc: "jpg",
+			data: []byte("\xff\xd8\xff\xe0\x00\x10JFIF"),
+			want: JPG,
+		},
+		{
+			desc: "png",
+			data: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+			want: PNG,
+		},
+		{
+			desc: "gif87a",
+			data: []byte("GIF87a\x01\x00"),
+			want: GIF,
+		},
+		{
+			desc: "gif89a",
+			data: []byte("GIF89a\x01\x00"),
+			want: GIF,
+		},
+		{
+			desc: "psd",
+			data: []byte("8BPS\x00\x01"),
+			want: PSD,
+		},
+		{
+			desc: "raf",
+			data: []byte("FUJIFILMCCD-RAW 0201FF383501"),
+			want: RAF,
+		},
+		{
+			desc: "iiq",
+			data: []byte("IIII\x01\x00\x00\x00"),
+			want: IIQ,
+		},
+		{
+			desc: "tiff little endian",
+			data: tiffHeader(binary.LittleEndian, "Canon", false),
+			want: TIF,
+		},
+		{
+			desc: "tiff big endian",
+			data: tiffHeader(binary.BigEndian, "Canon", false),
+			want: TIF,
+		},
+		{
+			desc: "tiff header only",
+			data: []byte("II*\x00\x08\x00\x00\x00"),
+			want: TIF,
+		},
+		{
+			desc: "cr2",
+			data: []byte("II*\x00\x10\x00\x00\x00CR\x02\x00"),
+			want: CR2,
+		},
+		{
+			desc: "nef",
+			data: tiffHeader(binary.BigEndian, "NIKON CORPORATION", false),
+			want: NEF,
+		},
+		{
+			desc: "dng",
+			data: tiffHeader(binary.LittleEndian, "Apple", true),
+			want: DNG,
+		},
+		{
+			desc: "dng from nikon",
+			data: tiffHeader(binary.LittleEndian, "NIKON CORPORATION", true),
+			want: DNG,
+		},
+		{
+			desc: "avi",
+			data: []byte("RIFF\x00\x00\x00\x00AVI LIST"),
+			want: AVI,
+		},
+		{
+			desc: "wav",
+			data: []byte("RIFF\x00\x00\x00\x00WAVEfmt "),
+			want: WAV,
+		},
+		{
+			desc:    "other riff",
+			data:    []byte("RIFF\x00\x00\x00\x00ACONanih"),
+			want:    UnknownType,
+			wantErr: ErrUnknownType,
+		},
+		{
+			desc: "mkv",
+			data: []byte("\x1a\x45\xdf\xa3\x01\x00"),
+			want: MKV,
+		},
+		{
+			desc: "mts",
+			data: append(append(append([]byte("\x00\x00\x00\x00\x47"), make([]byte, 191)...), '\x47'), make([]byte, 10)...),
+			want: MTS,
+		},
+		{
+			desc: "mp4",
+			data: []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00"),
+			want: MP4,
+		},
+		{
+			desc: "mov",
+			data: []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00"),
+			want: MOV,
+		},
+		{
+			desc: "m4v",
+			data: []byte("\x00\x00\x00\x18ftypM4V \x00\x00\x00\x01"),
+			want: M4V,
+		},
+		{
+			desc: "m4a",
+			data: []byte("\x00\x00\x00\x18ftypM4A \x00\x00\x00\x00"),
+			want: M4A,
+		},
+		{
+			desc: "3gp",
+			data: []byte("\x00\x00\x00\x14ftyp3gp4\x00\x00\x00\x00"),
+			want: ThreeGP,
+		},
+		{
+			desc: "mp3 with id3",
+			data: []byte("ID3\x03\x00\x00"),
+			want: MP3,
+		},
+		{
+			desc: "mp3 frame",
+			data: []byte("\xff\xfb\x90\x64"),
+			want: MP3,
+		},
+		{
+			desc: "webp",
+			data: []byte("RIFF\x00\x00\x00\x00WEBPVP8 "),
+			want: WEBP,
+		},
+		{
+			desc: "heic",
+			data: []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"),
+			want: HEIC,
+		},
+		{
+			desc: "heif",
+			data: []byte("\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00"),
+			want: HEIF,
+		},
+		{
+			desc: "avif",
+			data: []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00"),
+			want: AVIF,
+		},
+		{
+			desc: "cr3",
+			data: []byte("\x00\x00\x00\x18ftypcrx \x00\x00\x00\x01"),
+			want: CR3,
+		},
+		{
+			desc: "jxl codestream",
+			data: []byte("\xff\x0a\xfa\x1f"),
+			want: JXL,
+		},
+		{
+			desc: "jxl container",
+			data: []byte("\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a\x00\x00"),
+			want: JXL,
+		},
+		{
+			desc: "orf",
+			data: []byte("IIRO\x08\x00\x00\x00"),
+			want: ORF,
+		},
+		{
+			desc: "rw2",
+			data: []byte("IIU\x00\x18\x00\x00\x00"),
+			want: RW2,
+		},
+		{
+			desc: "arw",
+			data: tiffHeader(binary.LittleEndian, "SONY", false),
+			want: ARW,
+		},
+		{
+			desc: "pef",
+			data: tiffHeader(binary.LittleEndian, "PENTAX Corporation", false),
+			want: PEF,
+		},
+		{
+			desc: "srw",
+			data: tiffHeader(binary.LittleEndian, "SAMSUNG", false),
+			want: SRW,
+		},
+		{
+			desc:    "unknown",
+			data:    []byte("hello world"),
+			want:    UnknownType,
+			wantErr: ErrUnknownType,
+		},
+		{
+			desc:    "empty",
+			data:    []byte{},
+			want:    UnknownType,
+			wantErr: ErrUnknownType,
+		},
+	}
+	for _, tt := range tests {
+		got, err := DetectType(iotest.OneByteReader(bytes.NewReader(tt.data)))
+		if err != tt.wantErr {
+			t.Errorf("%q error got %v want %v", tt.desc, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%q got %v want %v", tt.desc, got, tt.want)
+		}
+	}
+
+	readErr := errors.New("read failed")
+	if _, err := DetectType(iotest.ErrReader(readErr)); err != readErr {
+		t.Errorf("read error got %v want %v", err, readErr)
+	}
+}
+
+func TestDetectFileType(t *testing.T) {
+	jpg := []byte("\xff\xd8\xff\xe0")
+	tests := []struct {
+		desc         string
+		name         string
+		data         []byte
+		want         Type
+		wantErr      error
+		wantMismatch bool
+	}{
+		{
+			desc: "matching",
+			name: "a.jpg",
+			data: jpg,
+			want: JPG,
+		},
+		{
+			desc: "no extension",
+			name: "IMG_0001",
+			data: jpg,
+			want: JPG,
+		},
+		{
+			desc:         "mismatch",
+			name:         "a.png",
+			data:         jpg,
+			want:         JPG,
+			wantMismatch: true,
+		},
+		{
+			desc: "unknown content uses extension",
+			name: "a.png",
+			data: []byte("hello"),
+			want: PNG,
+		},
+		{
+			desc:    "unknown content and extension",
+			name:    "a.txt",
+			data:    []byte("hello"),
+			want:    UnknownType,
+			wantErr: ErrUnknownType,
+		},
+	}
+	for _, tt := range tests {
+		got, err := DetectFileType(tt.name, bytes.NewReader(tt.data))
+		if got != tt.want {
+			t.Errorf("%q got %v want %v", tt.desc, got, tt.want)
+		}
+		if tt.wantMismatch {
+			merr, ok := err.(*TypeMismatchError)
+			if !ok {
+				t.Fatalf("%q error got %v want *TypeMismatchError", tt.desc, err)
+			}
+			if merr.Ext != PNG || merr.Content != JPG {
+				t.Errorf("%q mismatch got %#v", tt.desc, merr)
+			}
+			continue
+		}
+		if err != tt.wantErr {
+			t.Errorf("%q error got %v want %v", tt.desc, err, tt.wantErr)
+		}
+	}
+}
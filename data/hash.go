@@ -15,6 +15,8 @@ type Hash struct {
 
 var undefHash = Hash{}
 
+// hashFormat is the format used by NewHash. Hashes of any other format can
+// still be parsed and compared.
 const hashFormat = cid.SHA1
 
 // ParseHash converts a string to a Hash.
@@ -28,7 +30,13 @@ func ParseHash(s string) (Hash, error) {
 
 // NewHash generates a Hash from the data in reader.
 func NewHash(r io.Reader) (Hash, error) {
-	cid, err := cid.New(r, hashFormat)
+	return NewHashFormat(r, hashFormat)
+}
+
+// NewHashFormat generates a Hash from the data in reader, using the given
+// format. Use it to choose a stronger hash than the default.
+func NewHashFormat(r io.Reader, format cid.Format) (Hash, error) {
+	cid, err := cid.New(r, format)
 	if err != nil {
 		return undefHash, err
 	}
@@ -41,11 +49,24 @@ func LiteralHash(s string) Hash {
 	return Hash{cid.NewLiteral(s)}
 }
 
-// Equal returns true if the two hashes represent the same data.
+// Equal returns true if the two hashes represent the same data. Hashes with
+// the same digest are equal even if their cid formats differ, such as CidV0
+// and CidV1. Hashes calculated with different algorithms are never equal; to
+// relate those the data must be hashed again.
 func (h Hash) Equal(hh Hash) bool {
-	// NOTE: consider using EqualHash if we allow different cid formats.
-	return h.cid.Equal(hh.cid)
+	return h.cid.EqualHash(hh.cid)
+}
+
+// Key returns a string that's the same for hashes that are Equal, for use as a
+// map key.
+func (h Hash) Key() string {
+	return h.cid.Hash()
+}
 
+// Format returns the format that the Hash was calculated with. It returns
+// false if the format is unknown, for example a literal.
+func (h Hash) Format() (cid.Format, bool) {
+	return h.cid.Format()
 }
 
 // IsZero returns true if the Hash is its zero value.
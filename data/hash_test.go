@@ -22,6 +22,37 @@ func TestNewHash(t *testing.T) {
 	}
 }
 
+func TestNewHashFormat(t *testing.T) {
+	tests := []struct {
+		desc   string
+		format cid.Format
+		want   string
+	}{
+		{
+			desc:   "sha1",
+			format: cid.SHA1,
+			want:   "b8dfb080bc33fb564249e34252bf143d88fc018f",
+		},
+		{
+			desc:   "cidv1",
+			format: cid.CidV1,
+			want:   "zb2rhkQ5HMh8b8qj6V1xH42nvDKMYW7q54SLsi2W1mYtes8S4",
+		},
+	}
+	for _, tt := range tests {
+		hash, err := NewHashFormat(bytes.NewBufferString("testing 123"), tt.format)
+		if err != nil {
+			t.Fatalf("%q failed to new: %s", tt.desc, err)
+		}
+		if got, want := hash.String(), tt.want; got != want {
+			t.Errorf("%q got %s want %s", tt.desc, got, want)
+		}
+	}
+	if _, err := NewHashFormat(bytes.NewBufferString("a"), cid.Format(-1)); err == nil {
+		t.Errorf("unknown format must return an error")
+	}
+}
+
 func TestHashEqual(t *testing.T) {
 	h1, _ := NewHash(bytes.NewBufferString("a"))
 	h2, _ := NewHash(bytes.NewBufferString("a"))
@@ -33,7 +64,23 @@ func TestHashEqual(t *testing.T) {
 	if h1.Equal(h3) {
 		t.Errorf("different data must NOT be equal")
 	}
+
+	v0, _ := NewHashFormat(bytes.NewBufferString("a"), cid.CidV0)
+	v1, _ := NewHashFormat(bytes.NewBufferString("a"), cid.CidV1)
+	if !v0.Equal(v1) {
+		t.Errorf("same digest in different formats must be equal")
+	}
+	if h1.Equal(v1) {
+		t.Errorf("different digests of the same data must NOT be equal")
+	}
+	if v0.Key() != v1.Key() {
+		t.Errorf("equal hashes must have the same Key")
+	}
+	if h1.Key() == v1.Key() {
+		t.Errorf("different hashes must NOT have the same Key")
+	}
 }
+
 func TestHashIsZero(t *testing.T) {
 	tests := []struct {
 		desc     string
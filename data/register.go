@@ -0,0 +1,244 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TypeInfo describes a Type. It's used to register types that the package
+// doesn't define.
+type TypeInfo struct {
+
+	// Class is the category of data that the type belongs to.
+	Class Class
+
+	// MIME is the type's media types. The first is the canonical media
+	// type, others are recognized as aliases.
+	MIME []string
+
+	// Exts is alternate file extensions, without the leading dot, that
+	// are parsed as the type. The type itself is always its standard
+	// extension.
+	Exts []string
+
+	// Magic is the signatures that identify the type's content. Any one
+	// matching is sufficient.
+	Magic []Signature
+}
+
+// EncodingInfo describes an Encoding. It's used to register encodings that
+// the package doesn't define.
+type EncodingInfo struct {
+
+	// MIME is the encoding's media type.
+	MIME string
+
+	// NewWriter returns a writer that encodes data to w. Closing it must
+	// flush the encoding but not close w. If nil, data is stored as is.
+	NewWriter func(w io.Writer) io.WriteCloser
+
+	// NewReader returns a reader that decodes data from r. Closing it
+	// must not close r. If nil, data is read as is.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// Signature is a sequence of bytes found at a fixed offset in content.
+type Signature struct {
+	Offset int
+	Bytes  []byte
+}
+
+// match returns true if header contains the signature.
+func (s Signature) match(header []byte) bool {
+	end := s.Offset + len(s.Bytes)
+	return len(header) >= end && bytes.Equal(header[s.Offset:end], s.Bytes)
+}
+
+// typeSignature is a Signature of a Type.
+type typeSignature struct {
+	Signature
+	typ Type
+}
+
+var (
+	// registryMu guards types, encodings and the lookups derived from
+	// them.
+	registryMu sync.RWMutex
+
+	// exts maps alternate extensions to their type.
+	exts map[string]Type
+
+	// mimes maps media types to their type.
+	mimes map[string]Type
+
+	// signatures is all type signatures, longest first so that the most
+	// specific match wins.
+	signatures []typeSignature
+)
+
+func init() {
+	reindexTypes()
+}
+
+// RegisterType makes a new Type known to the package. Once registered it's
+// accepted by ParseType, Type.Ok and Type.Class, and so when decoding a
+// Stored from JSON or SQL; its signatures are used by DetectType and its
+// media types by TypeFromMIME. Types must be lower case. It's intended to be
+// called from init, and returns an error if the type is invalid or already
+// registered.
+func RegisterType(t Type, info TypeInfo) error {
+	if t == UnknownType || strings.Contains(string(t), ".") || strings.ToLower(string(t)) != string(t) {
+		return fmt.Errorf("data: invalid type %q", string(t))
+	}
+	for _, s := range info.Magic {
+		if len(s.Bytes) == 0 || s.Offset < 0 || s.Offset+len(s.Bytes) > sniffLen {
+			return fmt.Errorf("data: invalid signature for type %v", t)
+		}
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := types[t]; ok {
+		return fmt.Errorf("data: type %v is already registered", t)
+	}
+	if _, ok := exts[string(t)]; ok {
+		return fmt.Errorf("data: type %v is already registered as an extension", t)
+	}
+	if _, ok := encodings[Encoding(t)]; ok {
+		return fmt.Errorf("data: type %v is already registered as an encoding", t)
+	}
+	for _, m := range info.MIME {
+		if _, ok := mimes[strings.ToLower(m)]; ok {
+			return fmt.Errorf("data: media type %q of type %v is already registered", m, t)
+		}
+	}
+	for _, e := range info.Exts {
+		if _, ok := types[Type(e)]; ok {
+			return fmt.Errorf("data: extension %q of type %v is already registered", e, t)
+		}
+		if _, ok := exts[strings.ToLower(e)]; ok {
+			return fmt.Errorf("data: extension %q of type %v is already registered", e, t)
+		}
+		if _, ok := encodings[Encoding(strings.ToLower(e))]; ok {
+			return fmt.Errorf("data: extension %q of type %v is already registered as an encoding", e, t)
+		}
+	}
+	types[t] = info
+	reindexTypes()
+	return nil
+}
+
+// RegisterEncoding makes a new Encoding known to the package. Once
+// registered it's accepted by ParseType and Encoding.Ok, and its codec is
+// used by Stored.Encode and Stored.Decode. It's intended to be
+// called from init, and returns an error if the encoding is invalid or its
+// name is already registered as an encoding, type or extension.
+func RegisterEncoding(e Encoding, info EncodingInfo) error {
+	if e == Native || strings.Contains(string(e), ".") || strings.ToLower(string(e)) != string(e) {
+		return fmt.Errorf("data: invalid encoding %q", string(e))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := encodings[e]; ok {
+		return fmt.Errorf("data: encoding %v is already registered", e)
+	}
+	if _, ok := types[Type(e)]; ok {
+		return fmt.Errorf("data: encoding %v is already registered as a type", e)
+	}
+	if _, ok := exts[string(e)]; ok {
+		return fmt.Errorf("data: encoding %v is already registered as an extension", e)
+	}
+	encodings[e] = info
+	return nil
+}
+
+// TypeFromMIME returns the type of a media type, such as image/jpeg. Media
+// type parameters are ignored. ErrUnknownType is returned if no type has the
+// media type.
+func TypeFromMIME(mediaType string) (Type, error) {
+	m, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return UnknownType, ErrUnknownType
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	t, ok := mimes[m]
+	if !ok {
+		return UnknownType, ErrUnknownType
+	}
+	return t, nil
+}
+
+func lookupEncoding(e Encoding) (EncodingInfo, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	info, ok := encodings[e]
+	return info, ok
+}
+
+func lookupType(t Type) (TypeInfo, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	info, ok := types[t]
+	return info, ok
+}
+
+// typeFromExt returns the type of a standard or alternate extension, without
+// the leading dot. If it's unknown, the extension is returned as the type.
+func typeFromExt(ext string) (Type, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if _, ok := types[Type(ext)]; ok {
+		return Type(ext), true
+	}
+	if t, ok := exts[ext]; ok {
+		return t, true
+	}
+	return Type(ext), false
+}
+
+// matchSignature returns the type with the most specific signature matching
+// header.
+func matchSignature(header []byte) (Type, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, s := range signatures {
+		if s.match(header) {
+			return s.typ, true
+		}
+	}
+	return UnknownType, false
+}
+
+// reindexTypes rebuilds the lookups derived from types. The caller must hold
+// registryMu, or be init.
+func reindexTypes() {
+	exts = make(map[string]Type)
+	mimes = make(map[string]Type)
+	signatures = signatures[:0]
+	for t, info := range types {
+		for _, e := range info.Exts {
+			exts[strings.ToLower(e)] = t
+		}
+		for _, m := range info.MIME {
+			mimes[strings.ToLower(m)] = t
+		}
+		for _, s := range info.Magic {
+			signatures = append(signatures, typeSignature{s, t})
+		}
+	}
+	sort.Slice(signatures, func(i, j int) bool {
+		a, b := signatures[i], signatures[j]
+		if len(a.Bytes) != len(b.Bytes) {
+			return len(a.Bytes) > len(b.Bytes)
+		}
+		if a.Offset != b.Offset {
+			return a.Offset < b.Offset
+		}
+		return a.typ < b.typ
+	})
+}
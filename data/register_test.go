@@ -0,0 +1,155 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestRegisterType(t *testing.T) {
+	const x3f = Type("x3f")
+	err := RegisterType(x3f, TypeInfo{
+		Class: Image,
+		MIME:  []string{"image/x-sigma-x3f"},
+		Exts:  []string{"sigma"},
+		Magic: []Signature{{0, []byte("FOVb")}},
+	})
+	if err != nil {
+		t.Fatalf("RegisterType() failed: %s", err)
+	}
+	if err := RegisterEncoding(Encoding("br"), EncodingInfo{MIME: "application/x-brotli"}); err != nil {
+		t.Fatalf("RegisterEncoding() failed: %s", err)
+	}
+
+	if !x3f.Ok() {
+		t.Errorf("Ok() must be true")
+	}
+	if got, want := x3f.Class(), Class(Image); got != want {
+		t.Errorf("Class() got %s want %s", got, want)
+	}
+	if !Encoding("br").Ok() {
+		t.Errorf("Encoding Ok() must be true")
+	}
+
+	tests := []struct {
+		ext  string
+		want Stored
+	}{
+		{"x3f", Stored{Type: x3f}},
+		{".sigma", Stored{Type: x3f}},
+		{".SIGMA", Stored{Type: x3f}},
+		{"x3f.br", Stored{x3f, "br"}},
+		{"sigma.gz", Stored{x3f, GZip}},
+		{"jpg.br", Stored{JPG, "br"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseType(tt.ext)
+		if err != nil {
+			t.Errorf("ParseType(%q) failed: %s", tt.ext, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseType(%q) got %v want %v", tt.ext, got, tt.want)
+		}
+	}
+
+	if got, want := x3f.MIME(), "image/x-sigma-x3f"; got != want {
+		t.Errorf("MIME() got %s want %s", got, want)
+	}
+	if got, want := (Stored{x3f, "br"}).MIME(), "application/x-brotli"; got != want {
+		t.Errorf("Stored MIME() got %s want %s", got, want)
+	}
+	if got, _ := TypeFromMIME("image/x-sigma-x3f"); got != x3f {
+		t.Errorf("TypeFromMIME() got %v want %v", got, x3f)
+	}
+
+	got, err := DetectType(bytes.NewBufferString("FOVb\x00\x00"))
+	if err != nil {
+		t.Fatalf("DetectType() failed: %s", err)
+	}
+	if got != x3f {
+		t.Errorf("DetectType() got %v want %v", got, x3f)
+	}
+
+	var stored Stored
+	if err := json.Unmarshal([]byte(`"x3f.br"`), &stored); err != nil {
+		t.Fatalf("Stored UnmarshalJSON failed: %s", err)
+	}
+	if got, want := stored, (Stored{x3f, "br"}); got != want {
+		t.Errorf("Stored UnmarshalJSON got %v want %v", got, want)
+	}
+	stored = Stored{}
+	if err := stored.Scan([]byte("x3f")); err != nil {
+		t.Fatalf("Stored Scan failed: %s", err)
+	}
+	if got, want := stored, (Stored{Type: x3f}); got != want {
+		t.Errorf("Stored Scan got %v want %v", got, want)
+	}
+}
+
+func TestRegisterTypeErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		typ  Type
+		info TypeInfo
+	}{
+		{
+			desc: "unknown type",
+			typ:  UnknownType,
+		},
+		{
+			desc: "type with dot",
+			typ:  "a.b",
+		},
+		{
+			desc: "existing type",
+			typ:  JPG,
+		},
+		{
+			desc: "upper case",
+			typ:  "ABC",
+		},
+		{
+			desc: "existing media type",
+			typ:  "newmime",
+			info: TypeInfo{MIME: []string{"Image/JPEG"}},
+		},
+		{
+			desc: "existing extension",
+			typ:  "newjpg",
+			info: TypeInfo{Exts: []string{"jpg"}},
+		},
+		{
+			desc: "type is an existing extension",
+			typ:  "jpeg",
+		},
+		{
+			desc: "type is an existing encoding",
+			typ:  "gz",
+		},
+		{
+			desc: "extension is an existing encoding",
+			typ:  "newgz",
+			info: TypeInfo{Exts: []string{"GZ"}},
+		},
+		{
+			desc: "empty signature",
+			typ:  "newsig",
+			info: TypeInfo{Magic: []Signature{{0, nil}}},
+		},
+		{
+			desc: "signature out of range",
+			typ:  "newsig",
+			info: TypeInfo{Magic: []Signature{{sniffLen, []byte("a")}}},
+		},
+	}
+	for _, tt := range tests {
+		if err := RegisterType(tt.typ, tt.info); err == nil {
+			t.Errorf("%q must return an error", tt.desc)
+		}
+	}
+	for _, e := range []Encoding{Native, GZip, "a.b", "jpg", "jpeg"} {
+		if err := RegisterEncoding(e, EncodingInfo{}); err == nil {
+			t.Errorf("RegisterEncoding(%q) must return an error", e)
+		}
+	}
+}
@@ -17,16 +17,41 @@ const (
 	UnknownType Type = ""
 
 	// Image types
-	CR2 = "cr2" // Canon RAW file.
-	DNG = "dng" // Adobe Digital Negative file.
-	GIF = "gif" // Standard GIF file.
-	IIQ = "iiq" // Phase One RAW file.
-	JPG = "jpg" // Standard JPG file.
-	NEF = "nef" // Nikon RAW file.
-	PNG = "png" // Standard PNG file.
-	PSD = "psd" // Adobe Photoshop file.
-	RAF = "raf" // Fuji Raw file.
-	TIF = "tif" // Standard TIFF file.
+	ARW  = "arw"  // Sony RAW file.
+	AVIF = "avif" // AV1 Image File.
+	CR2  = "cr2"  // Canon RAW file.
+	CR3  = "cr3"  // Canon RAW file, version 3.
+	DNG  = "dng"  // Adobe Digital Negative file.
+	GIF  = "gif"  // Standard GIF file.
+	HEIC = "heic" // HEIF file with HEVC images, as made by iPhones.
+	HEIF = "heif" // High Efficiency Image File.
+	IIQ  = "iiq"  // Phase One RAW file.
+	JPG  = "jpg"  // Standard JPG file.
+	JXL  = "jxl"  // JPEG XL file.
+	NEF  = "nef"  // Nikon RAW file.
+	ORF  = "orf"  // Olympus RAW file.
+	PEF  = "pef"  // Pentax RAW file.
+	PNG  = "png"  // Standard PNG file.
+	PSD  = "psd"  // Adobe Photoshop file.
+	RAF  = "raf"  // Fuji Raw file.
+	RW2  = "rw2"  // Panasonic RAW file.
+	SRW  = "srw"  // Samsung RAW file.
+	TIF  = "tif"  // Standard TIFF file.
+	WEBP = "webp" // Google WebP file.
+
+	// Video types
+	AVI     = "avi" // Microsoft AVI file.
+	M4V     = "m4v" // Apple MPEG-4 video file.
+	MKV     = "mkv" // Matroska video file.
+	MOV     = "mov" // Apple QuickTime file.
+	MP4     = "mp4" // Standard MPEG-4 video file.
+	MTS     = "mts" // AVCHD video file.
+	ThreeGP = "3gp" // 3GPP mobile phone video file.
+
+	// Audio types
+	M4A = "m4a" // MPEG-4 audio file.
+	MP3 = "mp3" // Standard MP3 file.
+	WAV = "wav" // Standard WAVE file.
 )
 
 // Encoding definitions.
@@ -36,12 +61,16 @@ const (
 
 	Tar  = "tar"
 	GZip = "gz"
+	Zstd = "zst"
+	XZ   = "xz"
 )
 
 // Class definitions.
 const (
 	Unclassified Class = ""
 	Image              = "image"
+	Video              = "video"
+	Audio              = "audio"
 )
 
 // Type is a known kind of file such as JPEG or PNG.
@@ -64,19 +93,29 @@ func (t Type) Ext() string {
 
 // Class returns the type's class: image, catalog, etc.
 func (t Type) Class() Class {
-	td, ok := types[t]
+	info, ok := lookupType(t)
 	if !ok {
 		return Unclassified
 	}
-	return td.class
+	return info.Class
 }
 
 // Ok return true if the type is defined.
 func (t Type) Ok() bool {
-	_, ok := types[t]
+	_, ok := lookupType(t)
 	return ok
 }
 
+// MIME returns the type's canonical media type, such as image/jpeg. It's
+// empty if the type is unknown.
+func (t Type) MIME() string {
+	info, _ := lookupType(t)
+	if len(info.MIME) == 0 {
+		return ""
+	}
+	return info.MIME[0]
+}
+
 // Format implements fmt.Formatter.
 func (t Type) Format(f fmt.State, c rune) {
 	switch c {
@@ -93,8 +132,10 @@ func (t Type) Format(f fmt.State, c rune) {
 }
 
 // ParseType parses a type or extension, returning the Stored format.
+// Extensions are case insensitive, and alternate extensions such as jpeg or
+// tiff are resolved to the standard type.
 func ParseType(str string) (Stored, error) {
-	str = strings.TrimPrefix(str, ".")
+	str = strings.ToLower(strings.TrimPrefix(str, "."))
 	if str == "" {
 		return Stored{}, nil
 	}
@@ -103,14 +144,14 @@ func ParseType(str string) (Stored, error) {
 		return Stored{}, fmt.Errorf("data: too many parts in extension %q", str)
 	}
 	if len(parts) == 1 {
-		t := Type(parts[0])
-		if t.Ok() {
+		t, ok := typeFromExt(parts[0])
+		if ok {
 			return Stored{Type: t}, nil
 		}
 		e := Encoding(parts[0])
 		return Stored{Encoding: e}, fmt.Errorf("data: unknown type: %v", t)
 	}
-	t := Type(parts[0])
+	t, _ := typeFromExt(parts[0])
 	e := Native
 	if len(parts) == 2 {
 		e = Encoding(parts[1])
@@ -165,6 +206,16 @@ func (s Stored) Ok() bool {
 	return s.Type.Ok() && s.Encoding.Ok()
 }
 
+// MIME returns the media type of the stored data. If it has an encoding,
+// that's the encoding's media type, for example application/gzip for a
+// compressed JPG. Otherwise it's the type's media type.
+func (s Stored) MIME() string {
+	if s.Encoding != Native {
+		return s.Encoding.MIME()
+	}
+	return s.Type.MIME()
+}
+
 // Format implements fmt.Formatter.
 func (s Stored) Format(f fmt.State, c rune) {
 	switch c {
@@ -202,10 +253,17 @@ func (e Encoding) Ext() string {
 
 // Ok return true if the encoding is defined.
 func (e Encoding) Ok() bool {
-	_, ok := encodings[e]
+	_, ok := lookupEncoding(e)
 	return ok
 }
 
+// MIME returns the encoding's media type. It's empty for Native or if the
+// encoding is unknown.
+func (e Encoding) MIME() string {
+	info, _ := lookupEncoding(e)
+	return info.MIME
+}
+
 // Format implements fmt.Formatter.
 func (e Encoding) Format(f fmt.State, c rune) {
 	switch c {
@@ -229,27 +287,170 @@ func (c Class) String() string {
 	return string(c)
 }
 
-// td is type details
-type td struct {
-	class Class
+var types = map[Type]TypeInfo{
+	// keep alphabetized
+	ThreeGP: {
+		Class: Video,
+		MIME:  []string{"video/3gpp", "audio/3gpp"},
+	},
+	ARW: {
+		Class: Image,
+		MIME:  []string{"image/x-sony-arw"},
+	},
+	AVI: {
+		Class: Video,
+		MIME:  []string{"video/x-msvideo", "video/avi", "video/msvideo"},
+	},
+	AVIF: {
+		Class: Image,
+		MIME:  []string{"image/avif"},
+	},
+	CR2: {
+		Class: Image,
+		MIME:  []string{"image/x-canon-cr2"},
+	},
+	CR3: {
+		Class: Image,
+		MIME:  []string{"image/x-canon-cr3"},
+	},
+	DNG: {
+		Class: Image,
+		MIME:  []string{"image/x-adobe-dng"},
+	},
+	GIF: {
+		Class: Image,
+		MIME:  []string{"image/gif"},
+		Magic: []Signature{{0, []byte("GIF87a")}, {0, []byte("GIF89a")}},
+	},
+	HEIC: {
+		Class: Image,
+		MIME:  []string{"image/heic", "image/heic-sequence"},
+	},
+	HEIF: {
+		Class: Image,
+		MIME:  []string{"image/heif", "image/heif-sequence"},
+		Exts:  []string{"hif"},
+	},
+	IIQ: {
+		Class: Image,
+		MIME:  []string{"image/x-phaseone-iiq"},
+		Magic: []Signature{{0, []byte("IIII")}},
+	},
+	JPG: {
+		Class: Image,
+		MIME:  []string{"image/jpeg", "image/jpg", "image/pjpeg"},
+		Exts:  []string{"jpeg", "jpe"},
+		Magic: []Signature{{0, []byte("\xff\xd8\xff")}},
+	},
+	JXL: {
+		Class: Image,
+		MIME:  []string{"image/jxl"},
+		Magic: []Signature{{0, []byte("\xff\x0a")}, {0, []byte("\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a")}},
+	},
+	M4A: {
+		Class: Audio,
+		MIME:  []string{"audio/mp4", "audio/x-m4a"},
+	},
+	M4V: {
+		Class: Video,
+		MIME:  []string{"video/x-m4v"},
+	},
+	MKV: {
+		Class: Video,
+		MIME:  []string{"video/x-matroska"},
+		Magic: []Signature{{0, []byte("\x1a\x45\xdf\xa3")}},
+	},
+	MOV: {
+		Class: Video,
+		MIME:  []string{"video/quicktime"},
+		Exts:  []string{"qt"},
+	},
+	MP3: {
+		Class: Audio,
+		MIME:  []string{"audio/mpeg", "audio/mp3"},
+		Magic: []Signature{{0, []byte("ID3")}},
+	},
+	MP4: {
+		Class: Video,
+		MIME:  []string{"video/mp4"},
+	},
+	MTS: {
+		Class: Video,
+		MIME:  []string{"video/mp2t"},
+		Exts:  []string{"m2ts"},
+	},
+	NEF: {
+		Class: Image,
+		MIME:  []string{"image/x-nikon-nef"},
+	},
+	ORF: {
+		Class: Image,
+		MIME:  []string{"image/x-olympus-orf"},
+		Magic: []Signature{{0, []byte("IIRO")}, {0, []byte("IIRS")}, {0, []byte("MMOR")}},
+	},
+	PEF: {
+		Class: Image,
+		MIME:  []string{"image/x-pentax-pef"},
+	},
+	PNG: {
+		Class: Image,
+		MIME:  []string{"image/png"},
+		Magic: []Signature{{0, []byte("\x89PNG\r\n\x1a\n")}},
+	},
+	PSD: {
+		Class: Image,
+		MIME:  []string{"image/vnd.adobe.photoshop", "image/x-photoshop", "application/x-photoshop"},
+		Magic: []Signature{{0, []byte("8BPS")}},
+	},
+	RAF: {
+		Class: Image,
+		MIME:  []string{"image/x-fuji-raf"},
+		Magic: []Signature{{0, []byte("FUJIFILMCCD-RAW")}},
+	},
+	RW2: {
+		Class: Image,
+		MIME:  []string{"image/x-panasonic-rw2"},
+		Magic: []Signature{{0, []byte("IIU\x00")}},
+	},
+	SRW: {
+		Class: Image,
+		MIME:  []string{"image/x-samsung-srw"},
+	},
+	TIF: {
+		Class: Image,
+		MIME:  []string{"image/tiff"},
+		Exts:  []string{"tiff"},
+	},
+	WAV: {
+		Class: Audio,
+		MIME:  []string{"audio/wav", "audio/x-wav", "audio/wave", "audio/vnd.wave"},
+	},
+	WEBP: {
+		Class: Image,
+		MIME:  []string{"image/webp"},
+	},
 }
 
-var types = map[Type]td{
-	// keep alphabetized
-	CR2: {Image},
-	DNG: {Image},
-	GIF: {Image},
-	IIQ: {Image},
-	JPG: {Image},
-	NEF: {Image},
-	PNG: {Image},
-	PSD: {Image},
-	RAF: {Image},
-	TIF: {Image},
-}
-
-var encodings = map[Encoding]bool{
-	Native: true,
-	Tar:    true,
-	GZip:   true,
+var encodings = map[Encoding]EncodingInfo{
+	Native: {},
+	Tar: {
+		MIME:      "application/x-tar",
+		NewWriter: newTarWriter,
+		NewReader: newTarReader,
+	},
+	GZip: {
+		MIME:      "application/gzip",
+		NewWriter: newGZipWriter,
+		NewReader: newGZipReader,
+	},
+	Zstd: {
+		MIME:      "application/zstd",
+		NewWriter: newZstdWriter,
+		NewReader: newZstdReader,
+	},
+	XZ: {
+		MIME:      "application/x-xz",
+		NewWriter: newXZWriter,
+		NewReader: newXZReader,
+	},
 }
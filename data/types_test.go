@@ -125,6 +125,123 @@ func TestType(t *testing.T) {
 			wantFmtV:  "raf",
 			wantClass: Image,
 		},
+		{
+			desc:      "heic",
+			typ:       HEIC,
+			wantOk:    true,
+			wantStr:   "heic",
+			wantExt:   ".heic",
+			wantFmtV:  "heic",
+			wantClass: Image,
+		},
+		{
+			desc:      "avif",
+			typ:       AVIF,
+			wantOk:    true,
+			wantStr:   "avif",
+			wantExt:   ".avif",
+			wantFmtV:  "avif",
+			wantClass: Image,
+		},
+		{
+			desc:      "webp",
+			typ:       WEBP,
+			wantOk:    true,
+			wantStr:   "webp",
+			wantExt:   ".webp",
+			wantFmtV:  "webp",
+			wantClass: Image,
+		},
+		{
+			desc:      "jxl",
+			typ:       JXL,
+			wantOk:    true,
+			wantStr:   "jxl",
+			wantExt:   ".jxl",
+			wantFmtV:  "jxl",
+			wantClass: Image,
+		},
+		{
+			desc:      "cr3",
+			typ:       CR3,
+			wantOk:    true,
+			wantStr:   "cr3",
+			wantExt:   ".cr3",
+			wantFmtV:  "cr3",
+			wantClass: Image,
+		},
+		{
+			desc:      "arw",
+			typ:       ARW,
+			wantOk:    true,
+			wantStr:   "arw",
+			wantExt:   ".arw",
+			wantFmtV:  "arw",
+			wantClass: Image,
+		},
+		{
+			desc:      "orf",
+			typ:       ORF,
+			wantOk:    true,
+			wantStr:   "orf",
+			wantExt:   ".orf",
+			wantFmtV:  "orf",
+			wantClass: Image,
+		},
+		{
+			desc:      "rw2",
+			typ:       RW2,
+			wantOk:    true,
+			wantStr:   "rw2",
+			wantExt:   ".rw2",
+			wantFmtV:  "rw2",
+			wantClass: Image,
+		},
+		{
+			desc:      "mp4",
+			typ:       MP4,
+			wantOk:    true,
+			wantStr:   "mp4",
+			wantExt:   ".mp4",
+			wantFmtV:  "mp4",
+			wantClass: Video,
+		},
+		{
+			desc:      "mov",
+			typ:       MOV,
+			wantOk:    true,
+			wantStr:   "mov",
+			wantExt:   ".mov",
+			wantFmtV:  "mov",
+			wantClass: Video,
+		},
+		{
+			desc:      "3gp",
+			typ:       ThreeGP,
+			wantOk:    true,
+			wantStr:   "3gp",
+			wantExt:   ".3gp",
+			wantFmtV:  "3gp",
+			wantClass: Video,
+		},
+		{
+			desc:      "mp3",
+			typ:       MP3,
+			wantOk:    true,
+			wantStr:   "mp3",
+			wantExt:   ".mp3",
+			wantFmtV:  "mp3",
+			wantClass: Audio,
+		},
+		{
+			desc:      "wav",
+			typ:       WAV,
+			wantOk:    true,
+			wantStr:   "wav",
+			wantExt:   ".wav",
+			wantFmtV:  "wav",
+			wantClass: Audio,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.desc, func(t *testing.T) {
@@ -331,6 +448,16 @@ func TestParseType(t *testing.T) {
 			ext:  "jpg.gz",
 			want: Stored{JPG, GZip},
 		},
+		{
+			desc: "type and zstd",
+			ext:  "tif.zst",
+			want: Stored{TIF, Zstd},
+		},
+		{
+			desc: "type and xz",
+			ext:  ".psd.xz",
+			want: Stored{PSD, XZ},
+		},
 		{
 			desc:    "unknown type",
 			ext:     "foo.gz",
@@ -343,6 +470,21 @@ func TestParseType(t *testing.T) {
 			want:    Stored{JPG, "foo"},
 			wantErr: errors.New("data: unknown encoding: foo"),
 		},
+		{
+			desc: "upper case type",
+			ext:  ".JPG",
+			want: Stored{Type: JPG},
+		},
+		{
+			desc: "alternate extension",
+			ext:  ".jpeg",
+			want: Stored{Type: JPG},
+		},
+		{
+			desc: "upper case alternate extension and encoding",
+			ext:  ".TIFF.GZ",
+			want: Stored{TIF, GZip},
+		},
 		{
 			desc:    "too many parts (will support this later)",
 			ext:     "jpg.tar.gz",
@@ -361,3 +503,86 @@ func TestParseType(t *testing.T) {
 		})
 	}
 }
+
+func TestMIME(t *testing.T) {
+	tests := []struct {
+		desc   string
+		stored Stored
+		want   string
+	}{
+		{
+			desc:   "unknown",
+			stored: Stored{},
+			want:   "",
+		},
+		{
+			desc:   "undefined type",
+			stored: Stored{Type: "foo"},
+			want:   "",
+		},
+		{
+			desc:   "jpg",
+			stored: Stored{Type: JPG},
+			want:   "image/jpeg",
+		},
+		{
+			desc:   "mov",
+			stored: Stored{Type: MOV},
+			want:   "video/quicktime",
+		},
+		{
+			desc:   "gzip jpg",
+			stored: Stored{JPG, GZip},
+			want:   "application/gzip",
+		},
+		{
+			desc:   "tar",
+			stored: Stored{Encoding: Tar},
+			want:   "application/x-tar",
+		},
+	}
+	for _, tt := range tests {
+		if got, want := tt.stored.MIME(), tt.want; got != want {
+			t.Errorf("%q MIME() got %q want %q", tt.desc, got, want)
+		}
+	}
+	for typ := range types {
+		if typ.MIME() == "" {
+			t.Errorf("%v has no MIME", typ)
+			continue
+		}
+		got, err := TypeFromMIME(typ.MIME())
+		if err != nil {
+			t.Errorf("%v TypeFromMIME() failed: %s", typ, err)
+		}
+		if got != typ {
+			t.Errorf("%v TypeFromMIME() got %v", typ, got)
+		}
+	}
+}
+
+func TestTypeFromMIME(t *testing.T) {
+	tests := []struct {
+		mime    string
+		want    Type
+		wantErr error
+	}{
+		{"image/jpeg", JPG, nil},
+		{"IMAGE/JPEG", JPG, nil},
+		{"image/jpg", JPG, nil},
+		{"image/tiff; charset=binary", TIF, nil},
+		{"audio/mp4", M4A, nil},
+		{"video/mp4", MP4, nil},
+		{"text/plain", UnknownType, ErrUnknownType},
+		{"", UnknownType, ErrUnknownType},
+	}
+	for _, tt := range tests {
+		got, err := TypeFromMIME(tt.mime)
+		if err != tt.wantErr {
+			t.Errorf("%q error got %v want %v", tt.mime, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%q got %v want %v", tt.mime, got, tt.want)
+		}
+	}
+}
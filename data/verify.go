@@ -0,0 +1,112 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/recentralized/structure/cid"
+)
+
+var (
+	// ErrHashMismatch is returned if data does not match its expected Hash.
+	ErrHashMismatch = errors.New("data: hash mismatch")
+)
+
+// HashingWriter calculates the Hash of data while writing it to another
+// writer, so that data can be hashed and stored in a single pass.
+type HashingWriter struct {
+	w    io.Writer
+	h    *cid.Hasher
+	size int64
+}
+
+// NewHashingWriter initializes a HashingWriter that writes to w. The Hash is
+// calculated in the same format as NewHash.
+func NewHashingWriter(w io.Writer) *HashingWriter {
+	hw, err := NewHashingWriterFormat(w, hashFormat)
+	if err != nil {
+		panic(err)
+	}
+	return hw
+}
+
+// NewHashingWriterFormat initializes a HashingWriter that writes to w,
+// calculating the Hash in the given format.
+func NewHashingWriterFormat(w io.Writer, format cid.Format) (*HashingWriter, error) {
+	h, err := cid.NewHasher(format)
+	if err != nil {
+		return nil, err
+	}
+	return &HashingWriter{w: w, h: h}, nil
+}
+
+// Write implements io.Writer. Only the bytes successfully written to the
+// underlying writer are hashed.
+func (w *HashingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.h.Write(p[:n])
+	w.size += int64(n)
+	return n, err
+}
+
+// Hash returns the Hash of the data written so far.
+func (w *HashingWriter) Hash() (Hash, error) {
+	cid, err := w.h.Sum()
+	if err != nil {
+		return undefHash, err
+	}
+	return Hash{cid}, nil
+}
+
+// Size returns the number of bytes written so far.
+func (w *HashingWriter) Size() int64 {
+	return w.size
+}
+
+// NewVerifyingReader returns a reader that reads from r and calculates the
+// Hash of the data as it's read. At EOF, if the data does not match expected,
+// ErrHashMismatch is returned instead of io.EOF. The Hash is calculated in the
+// format of expected, so it must be a known format.
+func NewVerifyingReader(r io.Reader, expected Hash) io.Reader {
+	format, ok := expected.Format()
+	if !ok {
+		return &verifyingReader{err: fmt.Errorf("data: unknown hash format: %s", expected)}
+	}
+	h, err := cid.NewHasher(format)
+	if err != nil {
+		return &verifyingReader{err: err}
+	}
+	return &verifyingReader{r: r, h: h, expected: expected}
+}
+
+type verifyingReader struct {
+	r        io.Reader
+	h        *cid.Hasher
+	expected Hash
+	err      error
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF {
+		v.err = v.verify()
+		return n, v.err
+	}
+	return n, err
+}
+
+func (v *verifyingReader) verify() error {
+	cid, err := v.h.Sum()
+	if err != nil {
+		return err
+	}
+	if !(Hash{cid}).Equal(v.expected) {
+		return ErrHashMismatch
+	}
+	return io.EOF
+}
@@ -0,0 +1,122 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+
+	"github.com/recentralized/structure/cid"
+)
+
+func TestHashingWriter(t *testing.T) {
+	tests := []struct {
+		desc   string
+		format cid.Format
+	}{
+		{
+			desc:   "sha1",
+			format: cid.SHA1,
+		},
+		{
+			desc:   "cidv1",
+			format: cid.CidV1,
+		},
+	}
+	for _, tt := range tests {
+		want, err := NewHashFormat(bytes.NewBufferString("testing 123"), tt.format)
+		if err != nil {
+			t.Fatalf("%q NewHashFormat() failed: %s", tt.desc, err)
+		}
+		var buf bytes.Buffer
+		w, err := NewHashingWriterFormat(&buf, tt.format)
+		if err != nil {
+			t.Fatalf("%q NewHashingWriterFormat() failed: %s", tt.desc, err)
+		}
+		if _, err := io.Copy(w, iotest.OneByteReader(bytes.NewBufferString("testing 123"))); err != nil {
+			t.Fatalf("%q copy failed: %s", tt.desc, err)
+		}
+		got, err := w.Hash()
+		if err != nil {
+			t.Fatalf("%q Hash() failed: %s", tt.desc, err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("%q Hash() got %s want %s", tt.desc, got, want)
+		}
+		if got, want := w.Size(), int64(11); got != want {
+			t.Errorf("%q Size() got %d want %d", tt.desc, got, want)
+		}
+		if got, want := buf.String(), "testing 123"; got != want {
+			t.Errorf("%q written got %q want %q", tt.desc, got, want)
+		}
+	}
+
+	w := NewHashingWriter(ioutil.Discard)
+	w.Write([]byte("testing 123"))
+	got, _ := w.Hash()
+	want, _ := NewHash(bytes.NewBufferString("testing 123"))
+	if !got.Equal(want) {
+		t.Errorf("NewHashingWriter Hash() got %s want %s", got, want)
+	}
+}
+
+func TestVerifyingReader(t *testing.T) {
+	hash := func(s string, f cid.Format) Hash {
+		h, err := NewHashFormat(bytes.NewBufferString(s), f)
+		if err != nil {
+			t.Fatalf("failed to hash: %s", err)
+		}
+		return h
+	}
+	readErr := errors.New("read failed")
+	tests := []struct {
+		desc     string
+		r        io.Reader
+		expected Hash
+		wantErr  error
+		wantData string
+	}{
+		{
+			desc:     "sha1 match",
+			r:        bytes.NewBufferString("testing 123"),
+			expected: hash("testing 123", cid.SHA1),
+			wantData: "testing 123",
+		},
+		{
+			desc:     "cidv1 match",
+			r:        iotest.HalfReader(bytes.NewBufferString("testing 123")),
+			expected: hash("testing 123", cid.CidV1),
+			wantData: "testing 123",
+		},
+		{
+			desc:     "mismatch",
+			r:        bytes.NewBufferString("testing 124"),
+			expected: hash("testing 123", cid.SHA1),
+			wantErr:  ErrHashMismatch,
+		},
+		{
+			desc:     "read error",
+			r:        iotest.ErrReader(readErr),
+			expected: hash("testing 123", cid.SHA1),
+			wantErr:  readErr,
+		},
+	}
+	for _, tt := range tests {
+		data, err := ioutil.ReadAll(NewVerifyingReader(tt.r, tt.expected))
+		if got, want := err, tt.wantErr; got != want {
+			t.Errorf("%q error got %v want %v", tt.desc, got, want)
+		}
+		if tt.wantErr == nil {
+			if got, want := string(data), tt.wantData; got != want {
+				t.Errorf("%q data got %q want %q", tt.desc, got, want)
+			}
+		}
+	}
+
+	_, err := ioutil.ReadAll(NewVerifyingReader(bytes.NewBufferString("a"), LiteralHash("a")))
+	if err == nil {
+		t.Errorf("unknown hash format must return an error")
+	}
+}
@@ -0,0 +1,29 @@
+package dst
+
+import (
+	"github.com/recentralized/structure/data"
+)
+
+// Compression is a policy that decides how data is encoded when it's stored on
+// a destination. The zero value stores all data natively.
+type Compression struct {
+
+	// Classes maps a class of data to the encoding it's stored with.
+	Classes map[data.Class]data.Encoding
+
+	// Types maps a type of data to the encoding it's stored with. Types
+	// take precedence over Classes, so data.Native may be used to keep a
+	// type uncompressed within a compressed class.
+	Types map[data.Type]data.Encoding
+}
+
+// Stored returns the way data of type t is stored.
+func (c Compression) Stored(t data.Type) data.Stored {
+	if enc, ok := c.Types[t]; ok {
+		return data.Stored{Type: t, Encoding: enc}
+	}
+	if enc, ok := c.Classes[t.Class()]; ok {
+		return data.Stored{Type: t, Encoding: enc}
+	}
+	return data.Stored{Type: t}
+}
@@ -0,0 +1,61 @@
+package dst
+
+import (
+	"testing"
+
+	"github.com/recentralized/structure/data"
+)
+
+func TestCompressionStored(t *testing.T) {
+	archive := Compression{
+		Classes: map[data.Class]data.Encoding{
+			data.Image: data.Zstd,
+		},
+		Types: map[data.Type]data.Encoding{
+			data.JPG: data.Native,
+			data.PSD: data.XZ,
+		},
+	}
+	tests := []struct {
+		desc   string
+		policy Compression
+		typ    data.Type
+		want   data.Stored
+	}{
+		{
+			desc:   "zero value",
+			policy: Compression{},
+			typ:    data.TIF,
+			want:   data.Stored{Type: data.TIF},
+		},
+		{
+			desc:   "by class",
+			policy: archive,
+			typ:    data.TIF,
+			want:   data.Stored{Type: data.TIF, Encoding: data.Zstd},
+		},
+		{
+			desc:   "type overrides class",
+			policy: archive,
+			typ:    data.PSD,
+			want:   data.Stored{Type: data.PSD, Encoding: data.XZ},
+		},
+		{
+			desc:   "type kept native",
+			policy: archive,
+			typ:    data.JPG,
+			want:   data.Stored{Type: data.JPG},
+		},
+		{
+			desc:   "class not in policy",
+			policy: archive,
+			typ:    data.MOV,
+			want:   data.Stored{Type: data.MOV},
+		},
+	}
+	for _, tt := range tests {
+		if got, want := tt.policy.Stored(tt.typ), tt.want; got != want {
+			t.Errorf("%q Stored(%s) got %s want %s", tt.desc, tt.typ, got, want)
+		}
+	}
+}
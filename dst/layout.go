@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/recentralized/structure/cid"
 	"github.com/recentralized/structure/data"
 	"github.com/recentralized/structure/dst/files"
 	"github.com/recentralized/structure/meta"
@@ -15,7 +16,8 @@ import (
 // the destination's base URIs when needed.
 type Layout interface {
 
-	// NewHash generates the hash for data.
+	// NewHash generates the hash for data. Each layout chooses the
+	// format of its hashes.
 	NewHash(io.Reader) (data.Hash, error)
 
 	// IndexURI returns the document that stores the index.
@@ -26,6 +28,11 @@ type Layout interface {
 	// would allow you to shard the refs.
 	RefsURI(data.Hash) uri.URI
 
+	// DataType returns the way this data should be stored. It's the
+	// value for DstItem.DataType, and data should be written through its
+	// Encode method.
+	DataType(*meta.Meta) data.Stored
+
 	// DataURI returns the location that this data should be stored.
 	DataURI(data.Hash, *meta.Meta) uri.URI
 
@@ -43,13 +50,44 @@ type File struct {
 	Data []byte
 }
 
+// FilesystemOption customizes the standard filesystem layout.
+type FilesystemOption func(*fsLayout)
+
+// HashFormat generates hashes in the given format. Hashes already stored in
+// other formats remain valid.
+func HashFormat(format cid.Format) FilesystemOption {
+	return func(l *fsLayout) {
+		l.newHash = func(r io.Reader) (data.Hash, error) {
+			return data.NewHashFormat(r, format)
+		}
+	}
+}
+
+// Compress stores data as decided by the compression policy.
+func Compress(policy Compression) FilesystemOption {
+	return func(l *fsLayout) {
+		l.compression = policy
+	}
+}
+
 // NewFilesystemLayout initializes the standard layout for use on filesystems
 // and filesystem-like storage media such as AWS S3.
-func NewFilesystemLayout() Layout {
+func NewFilesystemLayout(opts ...FilesystemOption) Layout {
+	l := newFilesystemLayout()
+	for _, opt := range opts {
+		opt(&l)
+	}
+	return l
+}
+
+func newFilesystemLayout() fsLayout {
 	return fsLayout{
+		newHash:   data.NewHash,
 		indexFile: "index.json",
 		classToCategory: map[data.Class]string{
 			data.Image: "media",
+			data.Video: "media",
+			data.Audio: "media",
 		},
 		unknownCategory: "unknown",
 		zeroDateDir:     "Undated",
@@ -57,6 +95,8 @@ func NewFilesystemLayout() Layout {
 }
 
 type fsLayout struct {
+	newHash         func(io.Reader) (data.Hash, error)
+	compression     Compression
 	indexFile       string
 	classToCategory map[data.Class]string
 	unknownCategory string
@@ -64,7 +104,7 @@ type fsLayout struct {
 }
 
 func (l fsLayout) NewHash(r io.Reader) (data.Hash, error) {
-	return data.NewHash(r)
+	return l.newHash(r)
 }
 
 func (l fsLayout) IndexURI() uri.URI {
@@ -75,13 +115,17 @@ func (l fsLayout) RefsURI(hash data.Hash) uri.URI {
 	return l.IndexURI()
 }
 
+func (l fsLayout) DataType(meta *meta.Meta) data.Stored {
+	return l.compression.Stored(meta.Type)
+}
+
 // media/2006/2006-01-02/<hash>.<ext>
 // media/Undated/hash(<hash>)/<hash>.<ext>
 // <category>/hash(<hash>)/<hash>.<ext>
 func (l fsLayout) DataURI(hash data.Hash, meta *meta.Meta) uri.URI {
 	var (
 		key string
-		ext = meta.Type.Ext()
+		ext = l.DataType(meta).Ext()
 		cls = meta.Type.Class()
 	)
 
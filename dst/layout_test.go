@@ -1,9 +1,11 @@
 package dst
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
+	"github.com/recentralized/structure/cid"
 	"github.com/recentralized/structure/data"
 	"github.com/recentralized/structure/dst/files"
 	"github.com/recentralized/structure/meta"
@@ -42,6 +44,39 @@ func TestFilesystemLayout(t *testing.T) {
 			wantDataURI: "media/Undated/ab/cd/efg.jpg",
 			wantMetaURI: "meta/ab/cd/efg.json",
 		},
+		{
+			desc: "dated heic",
+			hash: data.LiteralHash("abcdefg"),
+			meta: &meta.Meta{
+				Type: data.HEIC,
+				Inherent: meta.Content{
+					Created: time.Date(2018, 9, 2, 9, 9, 9, 9, time.UTC),
+				},
+			},
+			wantDataURI: "media/2018/2018-09-02/abcdefg.heic",
+			wantMetaURI: "meta/ab/cd/efg.json",
+		},
+		{
+			desc: "dated video",
+			hash: data.LiteralHash("abcdefg"),
+			meta: &meta.Meta{
+				Type: data.MOV,
+				Inherent: meta.Content{
+					Created: time.Date(2015, 1, 2, 9, 9, 9, 9, time.UTC),
+				},
+			},
+			wantDataURI: "media/2015/2015-01-02/abcdefg.mov",
+			wantMetaURI: "meta/ab/cd/efg.json",
+		},
+		{
+			desc: "undated audio",
+			hash: data.LiteralHash("abcdefg"),
+			meta: &meta.Meta{
+				Type: data.M4A,
+			},
+			wantDataURI: "media/Undated/ab/cd/efg.m4a",
+			wantMetaURI: "meta/ab/cd/efg.json",
+		},
 		{
 			desc: "unknown class",
 			hash: data.LiteralHash("abcdefg"),
@@ -65,6 +100,90 @@ func TestFilesystemLayout(t *testing.T) {
 	}
 }
 
+func TestFilesystemLayoutCompression(t *testing.T) {
+	archive := NewFilesystemLayout(Compress(Compression{
+		Classes: map[data.Class]data.Encoding{data.Image: data.Zstd},
+	}))
+	browse := NewFilesystemLayout(Compress(Compression{
+		Classes: map[data.Class]data.Encoding{data.Image: data.Zstd},
+		Types:   map[data.Type]data.Encoding{data.JPG: data.Native},
+	}))
+	tests := []struct {
+		desc        string
+		layout      Layout
+		typ         data.Type
+		wantType    data.Stored
+		wantDataURI string
+	}{
+		{
+			desc:        "archive tif",
+			layout:      archive,
+			typ:         data.TIF,
+			wantType:    data.Stored{Type: data.TIF, Encoding: data.Zstd},
+			wantDataURI: "media/Undated/ab/cd/efg.tif.zst",
+		},
+		{
+			desc:        "archive jpg",
+			layout:      archive,
+			typ:         data.JPG,
+			wantType:    data.Stored{Type: data.JPG, Encoding: data.Zstd},
+			wantDataURI: "media/Undated/ab/cd/efg.jpg.zst",
+		},
+		{
+			desc:        "browse jpg",
+			layout:      browse,
+			typ:         data.JPG,
+			wantType:    data.Stored{Type: data.JPG},
+			wantDataURI: "media/Undated/ab/cd/efg.jpg",
+		},
+		{
+			desc:        "default",
+			layout:      NewFilesystemLayout(),
+			typ:         data.TIF,
+			wantType:    data.Stored{Type: data.TIF},
+			wantDataURI: "media/Undated/ab/cd/efg.tif",
+		},
+	}
+	for _, tt := range tests {
+		m := &meta.Meta{Type: tt.typ}
+		if got, want := tt.layout.DataType(m), tt.wantType; got != want {
+			t.Errorf("%q DataType() got %s want %s", tt.desc, got, want)
+		}
+		got := tt.layout.DataURI(data.LiteralHash("abcdefg"), m)
+		if got, want := got.String(), tt.wantDataURI; got != want {
+			t.Errorf("%q DataURI()\ngot  %s\nwant %s", tt.desc, got, want)
+		}
+	}
+}
+
+func TestFilesystemLayoutNewHash(t *testing.T) {
+	tests := []struct {
+		desc   string
+		layout Layout
+		want   string
+	}{
+		{
+			desc:   "default",
+			layout: NewFilesystemLayout(),
+			want:   "b8dfb080bc33fb564249e34252bf143d88fc018f",
+		},
+		{
+			desc:   "with hash",
+			layout: NewFilesystemLayout(HashFormat(cid.CidV1)),
+			want:   "zb2rhkQ5HMh8b8qj6V1xH42nvDKMYW7q54SLsi2W1mYtes8S4",
+		},
+	}
+	for _, tt := range tests {
+		hash, err := tt.layout.NewHash(bytes.NewBufferString("testing 123"))
+		if err != nil {
+			t.Fatalf("%q NewHash() failed: %s", tt.desc, err)
+		}
+		if got, want := hash.String(), tt.want; got != want {
+			t.Errorf("%q NewHash() got %s want %s", tt.desc, got, want)
+		}
+	}
+}
+
 func TestFilesytemLayoutFiles(t *testing.T) {
 	tests := []struct {
 		desc     string
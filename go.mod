@@ -2,8 +2,12 @@ module github.com/recentralized/structure
 
 require (
 	github.com/ipfs/go-cid v0.0.1
+	github.com/klauspost/compress v1.11.13
 	github.com/kr/pretty v0.1.0
 	github.com/multiformats/go-multihash v0.0.1
 	github.com/satori/go.uuid v1.2.0
+	github.com/ulikunitz/xz v0.5.15
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	modernc.org/sqlite v1.17.3
 )
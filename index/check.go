@@ -0,0 +1,427 @@
+package index
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/dst"
+	"github.com/recentralized/structure/uri"
+)
+
+// DstReader reads the files of a destination for Check. URIs are relative to
+// the destination, like those of a DstItem.
+type DstReader interface {
+
+	// Stat returns the size of the file at u. If there's no file the
+	// error satisfies os.IsNotExist.
+	Stat(u uri.URI) (int64, error)
+
+	// Open opens the file at u for reading.
+	Open(u uri.URI) (io.ReadCloser, error)
+
+	// Walk calls fn for every file in the destination. Walking stops if
+	// fn returns an error, which is returned.
+	Walk(fn func(u uri.URI) error) error
+}
+
+// NewDirReader initializes a DstReader for a destination stored in the
+// directory dir.
+func NewDirReader(dir string) DstReader {
+	return dirReader(dir)
+}
+
+type dirReader string
+
+func (d dirReader) path(u uri.URI) string {
+	return filepath.Join(string(d), filepath.FromSlash(u.String()))
+}
+
+func (d dirReader) Stat(u uri.URI) (int64, error) {
+	fi, err := os.Stat(d.path(u))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (d dirReader) Open(u uri.URI) (io.ReadCloser, error) {
+	return os.Open(d.path(u))
+}
+
+func (d dirReader) Walk(fn func(uri.URI) error) error {
+	return filepath.Walk(string(d), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(string(d), path)
+		if err != nil {
+			return err
+		}
+		return fn(uri.TrustedNew(filepath.ToSlash(rel)))
+	})
+}
+
+// ProblemKind is the kind of inconsistency found by Check.
+type ProblemKind string
+
+// The problems found by Check. Those that are repaired say how.
+const (
+	// MissingData is a DstItem whose DataURI doesn't exist. Repaired by
+	// removing the item.
+	MissingData ProblemKind = "missing_data"
+
+	// MissingMeta is a DstItem whose MetaURI doesn't exist.
+	MissingMeta ProblemKind = "missing_meta"
+
+	// DataSizeMismatch is a DstItem whose data isn't DataSize bytes.
+	// Repaired by setting DataSize if the data's hash is correct.
+	DataSizeMismatch ProblemKind = "data_size"
+
+	// MetaSizeMismatch is a DstItem whose meta isn't MetaSize bytes.
+	// Repaired by setting MetaSize.
+	MetaSizeMismatch ProblemKind = "meta_size"
+
+	// HashMismatch is a DstItem whose data doesn't have the ref's hash,
+	// or couldn't be decoded. Repaired by removing the item, but only if
+	// the data was decoded and has another hash.
+	HashMismatch ProblemKind = "hash_mismatch"
+
+	// OrphanFile is a file on the destination that isn't in the index.
+	OrphanFile ProblemKind = "orphan_file"
+
+	// UnknownSrc is a SrcItem whose SrcID isn't in the index.
+	UnknownSrc ProblemKind = "unknown_src"
+
+	// UnknownDst is a DstItem whose DstID isn't in the index.
+	UnknownDst ProblemKind = "unknown_dst"
+
+	// DuplicateSrcItem is a SrcItem with the same key as an earlier one
+	// in the ref. Repaired by removing it.
+	DuplicateSrcItem ProblemKind = "duplicate_src_item"
+
+	// DuplicateDstItem is a DstItem with the same key as an earlier one
+	// in the ref. Repaired by removing it.
+	DuplicateDstItem ProblemKind = "duplicate_dst_item"
+)
+
+// Problem is an inconsistency found by Check.
+type Problem struct {
+	Kind ProblemKind `json:"kind"`
+
+	// Hash is the ref with the problem. It's zero for an OrphanFile.
+	Hash data.Hash `json:"hash"`
+
+	// SrcID and DstID identify the item with the problem.
+	SrcID SrcID `json:"src_id,omitempty"`
+	DstID DstID `json:"dst_id,omitempty"`
+
+	// URI is the file with the problem, if any.
+	URI uri.URI `json:"uri"`
+
+	// Detail describes the problem.
+	Detail string `json:"detail,omitempty"`
+
+	// Repaired is true if the index was changed to fix the problem.
+	Repaired bool `json:"repaired"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("<Problem %s hash:%s uri:%q %s>", p.Kind, p.Hash, p.URI.String(), p.Detail)
+}
+
+// CheckOptions control Check.
+type CheckOptions struct {
+
+	// Repair changes the index to fix the problems that can be fixed
+	// within it. Files are never changed.
+	Repair bool
+
+	// SkipHashes skips reading the data of each item to verify its hash,
+	// which is the slowest part of a check.
+	SkipHashes bool
+}
+
+// CheckReport is the result of Check. It's suitable for JSON encoding.
+type CheckReport struct {
+
+	// Refs is the number of refs checked.
+	Refs int `json:"refs"`
+
+	// Files is the number of files found on the destination.
+	Files int `json:"files"`
+
+	// Problems are the inconsistencies found, in the order of the refs
+	// followed by orphan files.
+	Problems []Problem `json:"problems"`
+}
+
+// OK returns true if no problems were found.
+func (r CheckReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Check verifies that idx is consistent with itself and with the destination
+// dstID, stored by layout and read with r. Every ref is checked for items with
+// unknown SrcIDs or DstIDs and duplicate items. Each DstItem in dstID is
+// checked for its data and meta files and their sizes, and unless
+// opts.SkipHashes the data is read to verify the ref's hash. Finally every
+// file on the destination that's neither an item nor one of the layout's files
+// is reported as an orphan. Files next to the index, such as those written by
+// File, are not orphans.
+//
+// Data, meta and the index are expected to be stored under the one location
+// that r reads, as with NewDstAllAt.
+//
+// With opts.Repair the problems that can be are fixed in idx, as described by
+// each ProblemKind. Refs that are left with no items are removed. An error is
+// returned only if the destination can't be read.
+func Check(idx *Index, dstID DstID, layout dst.Layout, r DstReader, opts CheckOptions) (*CheckReport, error) {
+	c := &checker{
+		idx:      idx,
+		dstID:    dstID,
+		layout:   layout,
+		r:        r,
+		opts:     opts,
+		report:   &CheckReport{},
+		expected: make(map[string]bool),
+	}
+	c.expect(layout.IndexURI())
+	for _, f := range layout.Files() {
+		c.expect(f.URI)
+	}
+	refs := idx.Refs[:0:0]
+	for _, uref := range idx.Refs {
+		if err := c.checkRef(uref); err != nil {
+			return nil, err
+		}
+		if opts.Repair && len(uref.Srcs) == 0 && len(uref.Dsts) == 0 {
+			continue
+		}
+		refs = append(refs, uref)
+	}
+	if opts.Repair {
+		idx.Refs = refs
+		idx.Reindex()
+	}
+	if err := c.checkOrphans(); err != nil {
+		return nil, err
+	}
+	return c.report, nil
+}
+
+type checker struct {
+	idx      *Index
+	dstID    DstID
+	layout   dst.Layout
+	r        DstReader
+	opts     CheckOptions
+	report   *CheckReport
+	expected map[string]bool
+}
+
+func (c *checker) expect(u uri.URI) {
+	if !u.IsZero() {
+		c.expected[u.String()] = true
+	}
+}
+
+// add reports a problem, which is repaired if it can be and repair is on. It
+// returns true if it should be repaired.
+func (c *checker) add(p Problem, repairable bool) bool {
+	p.Repaired = repairable && c.opts.Repair
+	c.report.Problems = append(c.report.Problems, p)
+	return p.Repaired
+}
+
+func (c *checker) checkRef(uref *URef) error {
+	c.report.Refs++
+	c.expect(c.layout.RefsURI(uref.Hash))
+
+	var srcs []SrcItem
+	for n, src := range uref.Srcs {
+		if _, ok := c.idx.GetSrc(src.SrcID); !ok {
+			c.add(Problem{Kind: UnknownSrc, Hash: uref.Hash, SrcID: src.SrcID, URI: src.DataURI}, false)
+		}
+		if hasSrcKey(uref.Srcs[:n], src) {
+			if c.add(Problem{Kind: DuplicateSrcItem, Hash: uref.Hash, SrcID: src.SrcID, URI: src.DataURI}, true) {
+				continue
+			}
+		}
+		srcs = append(srcs, src)
+	}
+
+	var dsts []DstItem
+	for n, item := range uref.Dsts {
+		if _, ok := c.idx.GetDst(item.DstID); !ok {
+			c.add(Problem{Kind: UnknownDst, Hash: uref.Hash, DstID: item.DstID, URI: item.DataURI}, false)
+		}
+		if hasDstKey(uref.Dsts[:n], item) {
+			if c.add(Problem{Kind: DuplicateDstItem, Hash: uref.Hash, DstID: item.DstID, URI: item.DataURI}, true) {
+				continue
+			}
+		}
+		if item.DstID == c.dstID {
+			var keep bool
+			var err error
+			item, keep, err = c.checkDstItem(uref, item)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				continue
+			}
+		}
+		dsts = append(dsts, item)
+	}
+
+	if c.opts.Repair {
+		uref.Srcs = srcs
+		uref.Dsts = dsts
+	}
+	return nil
+}
+
+// checkDstItem checks the files of item. It returns the item as repaired, and
+// false if it should be removed.
+func (c *checker) checkDstItem(uref *URef, item DstItem) (DstItem, bool, error) {
+	c.expect(item.DataURI)
+	c.expect(item.MetaURI)
+	problem := func(kind ProblemKind, u uri.URI, detail string) Problem {
+		return Problem{Kind: kind, Hash: uref.Hash, DstID: item.DstID, URI: u, Detail: detail}
+	}
+
+	size, err := c.r.Stat(item.DataURI)
+	if os.IsNotExist(err) {
+		return item, !c.add(problem(MissingData, item.DataURI, ""), true), nil
+	}
+	if err != nil {
+		return item, true, fmt.Errorf("index: check %s: %s", item.DataURI, err)
+	}
+	hashOK := !c.opts.SkipHashes
+	if !c.opts.SkipHashes {
+		detail, repairable, err := c.verify(uref, item)
+		if os.IsNotExist(err) {
+			return item, !c.add(problem(MissingData, item.DataURI, ""), true), nil
+		}
+		if err != nil {
+			return item, true, fmt.Errorf("index: check %s: %s", item.DataURI, err)
+		}
+		if detail != "" {
+			if c.add(problem(HashMismatch, item.DataURI, detail), repairable) {
+				return item, false, nil
+			}
+			hashOK = false
+		}
+	}
+	if size != item.DataSize {
+		detail := fmt.Sprintf("size %d, index has %d", size, item.DataSize)
+		if c.add(problem(DataSizeMismatch, item.DataURI, detail), hashOK) {
+			item.DataSize = size
+		}
+	}
+
+	if item.MetaURI.IsZero() {
+		return item, true, nil
+	}
+	size, err = c.r.Stat(item.MetaURI)
+	if os.IsNotExist(err) {
+		c.add(problem(MissingMeta, item.MetaURI, ""), false)
+		return item, true, nil
+	}
+	if err != nil {
+		return item, true, fmt.Errorf("index: check %s: %s", item.MetaURI, err)
+	}
+	if size != item.MetaSize {
+		detail := fmt.Sprintf("size %d, index has %d", size, item.MetaSize)
+		if c.add(problem(MetaSizeMismatch, item.MetaURI, detail), true) {
+			item.MetaSize = size
+		}
+	}
+	return item, true, nil
+}
+
+// verify reads the data of item and hashes it in the format of the ref's
+// hash. It returns a description of the problem if it doesn't match, and
+// whether it's repairable, which is only if the data was read and has another
+// hash. Data that can't be decoded is a problem that isn't repairable. An
+// error opening or reading the data is returned.
+func (c *checker) verify(uref *URef, item DstItem) (string, bool, error) {
+	rc, err := c.r.Open(item.DataURI)
+	if err != nil {
+		return "", false, err
+	}
+	defer rc.Close()
+	r := &readErrRecorder{r: rc}
+	dec := item.DataType.Decode(r)
+	defer dec.Close()
+	var hash data.Hash
+	if format, ok := uref.Hash.Format(); ok {
+		hash, err = data.NewHashFormat(dec, format)
+	} else {
+		hash, err = c.layout.NewHash(dec)
+	}
+	if r.err != nil {
+		return "", false, r.err
+	}
+	if err != nil {
+		return err.Error(), false, nil
+	}
+	if !uref.HasHash(hash) {
+		return fmt.Sprintf("hash is %s", hash), true, nil
+	}
+	return "", false, nil
+}
+
+// readErrRecorder records the error of reading from r, so that it can be
+// told apart from errors decoding what was read.
+type readErrRecorder struct {
+	r   io.Reader
+	err error
+}
+
+func (r *readErrRecorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+func (c *checker) checkOrphans() error {
+	// Files that share the index's name, such as backups, belong to it.
+	indexPrefix := c.layout.IndexURI().String() + "."
+	return c.r.Walk(func(u uri.URI) error {
+		c.report.Files++
+		key := u.String()
+		if c.expected[key] || strings.HasPrefix(key, indexPrefix) {
+			return nil
+		}
+		c.add(Problem{Kind: OrphanFile, URI: u}, false)
+		return nil
+	})
+}
+
+func hasSrcKey(items []SrcItem, item SrcItem) bool {
+	for _, s := range items {
+		if s.EqualKey(item) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDstKey(items []DstItem, item DstItem) bool {
+	for _, d := range items {
+		if d.EqualKey(item) {
+			return true
+		}
+	}
+	return false
+}
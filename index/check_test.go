@@ -0,0 +1,255 @@
+package index_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/dst"
+	"github.com/recentralized/structure/index"
+	"github.com/recentralized/structure/uri"
+)
+
+func TestCheck(t *testing.T) {
+	dir := tempDir(t)
+	write := func(name, content string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+	hash := func(content string) data.Hash {
+		h, err := data.NewHash(bytes.NewBufferString(content))
+		if err != nil {
+			t.Fatalf("failed to hash: %s", err)
+		}
+		return h
+	}
+
+	src := index.NewSrc(uri.TrustedNew("file:///src/"))
+	d := index.NewDstAllAt(uri.TrustedNew("file:///dst/"))
+	idx := index.New()
+	idx.AddSrc(src)
+	idx.AddDst(d)
+	add := func(content, name string, dataSize, metaSize int64) data.Hash {
+		h := hash(content)
+		idx.AddRef(index.Ref{
+			Hash: h,
+			Src:  index.SrcItem{SrcID: src.SrcID, DataURI: uri.TrustedNew("file:///src/" + name)},
+			Dst: index.DstItem{
+				DstID:    d.DstID,
+				DataURI:  uri.TrustedNew("media/" + name),
+				MetaURI:  uri.TrustedNew("meta/" + name + ".json"),
+				DataType: data.Stored{Type: data.JPG},
+				DataSize: dataSize,
+				MetaSize: metaSize,
+			},
+		})
+		return h
+	}
+	good := add("good", "good.jpg", 4, 2)
+	write("media/good.jpg", "good")
+	write("meta/good.jpg.json", "{}")
+
+	missing := add("missing", "missing.jpg", 7, 2)
+	write("meta/missing.jpg.json", "{}")
+
+	corrupt := add("corrupt", "corrupt.jpg", 7, 2)
+	write("media/corrupt.jpg", "CORRUPT")
+	write("meta/corrupt.jpg.json", "{}")
+
+	sizes := add("sizes", "sizes.jpg", 1, 1)
+	write("media/sizes.jpg", "sizes")
+	write("meta/sizes.jpg.json", "{}")
+
+	dups := add("dups", "dups.jpg", 4, 2)
+	write("media/dups.jpg", "dups")
+	write("meta/dups.jpg.json", "{}")
+	uref, _ := idx.GetRef(dups)
+	uref.Dsts = append(uref.Dsts, uref.Dsts[0])
+	uref.Srcs = append(uref.Srcs, index.SrcItem{SrcID: "unknown"})
+
+	write("index.json", "{}")
+	write("index.json.1", "{}")
+	write("README.txt", "hi")
+	write("media/orphan.jpg", "orphan")
+
+	layout := dst.NewFilesystemLayout()
+	report, err := index.Check(idx, d.DstID, layout, index.NewDirReader(dir), index.CheckOptions{})
+	if err != nil {
+		t.Fatalf("Check() failed: %s", err)
+	}
+	if got, want := report.Refs, 5; got != want {
+		t.Errorf("Refs got %d want %d", got, want)
+	}
+	if got, want := report.Files, 13; got != want {
+		t.Errorf("Files got %d want %d", got, want)
+	}
+	want := []struct {
+		kind index.ProblemKind
+		hash data.Hash
+		uri  string
+	}{
+		{index.MissingData, missing, "media/missing.jpg"},
+		{index.HashMismatch, corrupt, "media/corrupt.jpg"},
+		{index.DataSizeMismatch, sizes, "media/sizes.jpg"},
+		{index.MetaSizeMismatch, sizes, "meta/sizes.jpg.json"},
+		{index.UnknownSrc, dups, ""},
+		{index.DuplicateDstItem, dups, "media/dups.jpg"},
+		{index.OrphanFile, data.Hash{}, "media/orphan.jpg"},
+	}
+	if got, want := len(report.Problems), len(want); got != want {
+		t.Fatalf("got %d problems want %d: %v", got, want, report.Problems)
+	}
+	for n, p := range report.Problems {
+		w := want[n]
+		if p.Kind != w.kind || !p.Hash.Equal(w.hash) || p.URI.String() != w.uri || p.Repaired {
+			t.Errorf("problem %d got %s want %s %s %q", n, p, w.kind, w.hash, w.uri)
+		}
+	}
+	if _, err := json.Marshal(report); err != nil {
+		t.Errorf("report must encode as JSON: %s", err)
+	}
+	if got := len(idx.Refs); got != 5 {
+		t.Errorf("Check() without Repair must not change the index")
+	}
+
+	report, err = index.Check(idx, d.DstID, layout, index.NewDirReader(dir), index.CheckOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Check(Repair) failed: %s", err)
+	}
+	var repaired int
+	for _, p := range report.Problems {
+		if p.Repaired {
+			repaired++
+		}
+	}
+	if got, want := repaired, 5; got != want {
+		t.Errorf("repaired %d problems want %d: %v", got, want, report.Problems)
+	}
+	if _, ok := idx.GetDstItem(missing, d.DstID); ok {
+		t.Errorf("item with missing data must be removed")
+	}
+	if _, ok := idx.GetDstItem(corrupt, d.DstID); ok {
+		t.Errorf("item with corrupt data must be removed")
+	}
+	if item, _ := idx.GetDstItem(sizes, d.DstID); item.DataSize != 5 || item.MetaSize != 2 {
+		t.Errorf("sizes got %d and %d want 5 and 2", item.DataSize, item.MetaSize)
+	}
+	if uref, _ := idx.GetRef(dups); len(uref.Dsts) != 1 {
+		t.Errorf("duplicate item must be removed")
+	}
+	if _, ok := idx.GetRef(good); !ok {
+		t.Errorf("good ref must be kept")
+	}
+
+	// What's left can't be repaired in the index. The files of removed
+	// items are now orphans.
+	report, err = index.Check(idx, d.DstID, layout, index.NewDirReader(dir), index.CheckOptions{SkipHashes: true})
+	if err != nil {
+		t.Fatalf("Check() after repair failed: %s", err)
+	}
+	var kinds []index.ProblemKind
+	for _, p := range report.Problems {
+		kinds = append(kinds, p.Kind)
+	}
+	if got, want := len(kinds), 5; got != want {
+		t.Errorf("after repair got problems %v want unknown src and four orphans", kinds)
+	}
+}
+
+// failingReader fails to open files if openErr, and otherwise fails to read
+// them after the first byte.
+type failingReader struct {
+	index.DstReader
+	openErr bool
+}
+
+var errIO = errors.New("input/output error")
+
+func (r failingReader) Open(u uri.URI) (io.ReadCloser, error) {
+	if r.openErr {
+		return nil, errIO
+	}
+	rc, err := r.DstReader.Open(u)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(io.LimitReader(rc, 1), iotest.ErrReader(errIO)), rc}, nil
+}
+
+func TestCheckReadErrors(t *testing.T) {
+	dir := tempDir(t)
+	write := func(name, content string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+	d := index.NewDstAllAt(uri.TrustedNew("file:///dst/"))
+	idx := index.New()
+	idx.AddDst(d)
+	h, _ := data.NewHash(bytes.NewBufferString("data"))
+	idx.AddRef(index.Ref{
+		Hash: h,
+		Dst: index.DstItem{
+			DstID:    d.DstID,
+			DataURI:  uri.TrustedNew("media/a.jpg.gz"),
+			DataType: data.Stored{Type: data.JPG, Encoding: data.GZip},
+			DataSize: 4,
+		},
+	})
+	write("media/a.jpg.gz", "data")
+	layout := dst.NewFilesystemLayout()
+	opts := index.CheckOptions{Repair: true}
+
+	// Errors reading the destination are returned, and nothing is repaired.
+	for _, r := range []index.DstReader{
+		failingReader{index.NewDirReader(dir), true},
+		failingReader{index.NewDirReader(dir), false},
+	} {
+		if _, err := index.Check(idx, d.DstID, layout, r, opts); err == nil {
+			t.Errorf("Check() must fail if data can't be read")
+		}
+		if _, ok := idx.GetDstItem(h, d.DstID); !ok {
+			t.Errorf("Check() must not remove data that can't be read")
+		}
+	}
+
+	// Data that can't be decoded is reported but not removed.
+	report, err := index.Check(idx, d.DstID, layout, index.NewDirReader(dir), opts)
+	if err != nil {
+		t.Fatalf("Check() failed: %s", err)
+	}
+	var mismatches int
+	for _, p := range report.Problems {
+		if p.Kind == index.HashMismatch {
+			mismatches++
+			if p.Repaired {
+				t.Errorf("Check() must not repair %v", p)
+			}
+		}
+	}
+	if mismatches != 1 {
+		t.Errorf("Check() got %v want a hash mismatch", report.Problems)
+	}
+	if _, ok := idx.GetDstItem(h, d.DstID); !ok {
+		t.Errorf("Check() must not remove data that can't be decoded")
+	}
+}
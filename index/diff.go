@@ -0,0 +1,296 @@
+package index
+
+import (
+	"github.com/recentralized/structure/data"
+)
+
+// Changes is the set of changes from one index to another. It's serializable as
+// JSON, to be printed as a change log or sent elsewhere and applied as a patch
+// with Index.Apply.
+type Changes struct {
+	AddedSrcs   []Src `json:"added_srcs,omitempty"`
+	RemovedSrcs []Src `json:"removed_srcs,omitempty"`
+	ChangedSrcs []Src `json:"changed_srcs,omitempty"`
+
+	AddedDsts   []Dst `json:"added_dsts,omitempty"`
+	RemovedDsts []Dst `json:"removed_dsts,omitempty"`
+	ChangedDsts []Dst `json:"changed_dsts,omitempty"`
+
+	AddedRefs   []*URef      `json:"added_refs,omitempty"`
+	RemovedRefs []data.Hash  `json:"removed_refs,omitempty"`
+	ChangedRefs []RefChanges `json:"changed_refs,omitempty"`
+}
+
+// RefChanges is the set of changes to a ref that's in both indexes.
+type RefChanges struct {
+
+	// Hash is the ref's Hash in the new index.
+	Hash data.Hash `json:"hash"`
+
+	// HashChanged is true if the ref's Hash or Aliases have changed, in
+	// which case Aliases is the ref's complete set of Aliases in the new
+	// index.
+	HashChanged bool        `json:"hash_changed,omitempty"`
+	Aliases     []data.Hash `json:"aliases,omitempty"`
+
+	AddedSrcs   []SrcItem `json:"added_srcs,omitempty"`
+	RemovedSrcs []SrcItem `json:"removed_srcs,omitempty"`
+	ChangedSrcs []SrcItem `json:"changed_srcs,omitempty"`
+
+	AddedDsts   []DstItem `json:"added_dsts,omitempty"`
+	RemovedDsts []DstItem `json:"removed_dsts,omitempty"`
+	ChangedDsts []DstItem `json:"changed_dsts,omitempty"`
+}
+
+// IsEmpty returns true if there are no changes.
+func (d *Changes) IsEmpty() bool {
+	return len(d.AddedSrcs) == 0 && len(d.RemovedSrcs) == 0 && len(d.ChangedSrcs) == 0 &&
+		len(d.AddedDsts) == 0 && len(d.RemovedDsts) == 0 && len(d.ChangedDsts) == 0 &&
+		len(d.AddedRefs) == 0 && len(d.RemovedRefs) == 0 && len(d.ChangedRefs) == 0
+}
+
+func (r *RefChanges) isEmpty() bool {
+	return !r.HashChanged &&
+		len(r.AddedSrcs) == 0 && len(r.RemovedSrcs) == 0 && len(r.ChangedSrcs) == 0 &&
+		len(r.AddedDsts) == 0 && len(r.RemovedDsts) == 0 && len(r.ChangedDsts) == 0
+}
+
+// Diff returns the changes from one index to another. Srcs and Dsts are matched by ID,
+// refs by any of their hashes, and items by EqualKey. An item has changed if
+// it's not Equal.
+func Diff(from, to *Index) *Changes {
+	d := &Changes{}
+
+	for _, src := range to.Srcs {
+		o, ok := from.GetSrc(src.SrcID)
+		switch {
+		case !ok:
+			d.AddedSrcs = append(d.AddedSrcs, src)
+		case !o.SrcURI.Equal(src.SrcURI):
+			d.ChangedSrcs = append(d.ChangedSrcs, src)
+		}
+	}
+	for _, src := range from.Srcs {
+		if _, ok := to.GetSrc(src.SrcID); !ok {
+			d.RemovedSrcs = append(d.RemovedSrcs, src)
+		}
+	}
+
+	for _, dst := range to.Dsts {
+		o, ok := from.GetDst(dst.DstID)
+		switch {
+		case !ok:
+			d.AddedDsts = append(d.AddedDsts, dst)
+		case !o.IndexURI.Equal(dst.IndexURI), !o.DataURI.Equal(dst.DataURI), !o.MetaURI.Equal(dst.MetaURI):
+			d.ChangedDsts = append(d.ChangedDsts, dst)
+		}
+	}
+	for _, dst := range from.Dsts {
+		if _, ok := to.GetDst(dst.DstID); !ok {
+			d.RemovedDsts = append(d.RemovedDsts, dst)
+		}
+	}
+
+	for _, uref := range to.Refs {
+		o, ok := from.findAnyRef(uref)
+		if !ok {
+			d.AddedRefs = append(d.AddedRefs, uref)
+			continue
+		}
+		if rd := diffRef(o, uref); !rd.isEmpty() {
+			d.ChangedRefs = append(d.ChangedRefs, rd)
+		}
+	}
+	for _, uref := range from.Refs {
+		if _, ok := to.findAnyRef(uref); !ok {
+			d.RemovedRefs = append(d.RemovedRefs, uref.Hash)
+		}
+	}
+	return d
+}
+
+func diffRef(from, to *URef) RefChanges {
+	rd := RefChanges{Hash: to.Hash}
+	if !from.Hash.Equal(to.Hash) || !equalHashes(from.Aliases, to.Aliases) {
+		rd.HashChanged = true
+		rd.Aliases = to.Aliases
+	}
+	for _, s := range to.Srcs {
+		n := from.srcIndex(s)
+		switch {
+		case n < 0:
+			rd.AddedSrcs = append(rd.AddedSrcs, s)
+		case !from.Srcs[n].Equal(s):
+			rd.ChangedSrcs = append(rd.ChangedSrcs, s)
+		}
+	}
+	for _, s := range from.Srcs {
+		if to.srcIndex(s) < 0 {
+			rd.RemovedSrcs = append(rd.RemovedSrcs, s)
+		}
+	}
+	for _, d := range to.Dsts {
+		n := from.dstIndex(d)
+		switch {
+		case n < 0:
+			rd.AddedDsts = append(rd.AddedDsts, d)
+		case !from.Dsts[n].Equal(d):
+			rd.ChangedDsts = append(rd.ChangedDsts, d)
+		}
+	}
+	for _, d := range from.Dsts {
+		if to.dstIndex(d) < 0 {
+			rd.RemovedDsts = append(rd.RemovedDsts, d)
+		}
+	}
+	return rd
+}
+
+func equalHashes(a, b []data.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if !a[n].Equal(b[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply applies the changes in d to the index, such as to replay changes made
+// to a copy of it. Changes are applied leniently so that an index that has
+// diverged can still be patched: removing something that doesn't exist does
+// nothing, and changing a ref that doesn't exist adds it. It returns true if
+// the index was modified.
+func (i *Index) Apply(d *Changes) bool {
+	var changed bool
+	for _, src := range d.AddedSrcs {
+		changed = i.AddSrc(src) || changed
+	}
+	for _, src := range d.ChangedSrcs {
+		changed = i.replaceSrc(src) || changed
+	}
+	for _, dst := range d.AddedDsts {
+		changed = i.AddDst(dst) || changed
+	}
+	for _, dst := range d.ChangedDsts {
+		changed = i.replaceDst(dst) || changed
+	}
+	for _, uref := range d.AddedRefs {
+		rd := RefChanges{
+			Hash:      uref.Hash,
+			Aliases:   uref.Aliases,
+			AddedSrcs: uref.Srcs,
+			AddedDsts: uref.Dsts,
+		}
+		changed = i.applyRef(rd) || changed
+	}
+	for _, rd := range d.ChangedRefs {
+		changed = i.applyRef(rd) || changed
+	}
+	for _, hash := range d.RemovedRefs {
+		changed = i.RemoveRef(hash) || changed
+	}
+	for _, src := range d.RemovedSrcs {
+		changed = i.RemoveSrc(src.SrcID) || changed
+	}
+	for _, dst := range d.RemovedDsts {
+		changed = i.RemoveDst(dst.DstID, KeepOrphans) || changed
+	}
+	return changed
+}
+
+func (i *Index) applyRef(rd RefChanges) bool {
+	var changed bool
+	uref, ok := i.findAnyRef(&URef{Hash: rd.Hash, Aliases: rd.Aliases})
+	if ok {
+		i.unindexRef(uref)
+	} else {
+		uref = &URef{Hash: rd.Hash}
+		i.Refs = append(i.Refs, uref)
+		i.lookup.refsShape = shapeOfRefs(i.Refs)
+		changed = true
+	}
+	if rd.HashChanged {
+		changed = uref.SetHash(rd.Hash) || changed
+		changed = uref.setAliases(rd.Aliases) || changed
+	} else {
+		for _, a := range rd.Aliases {
+			changed = uref.AddAlias(a) || changed
+		}
+		changed = uref.SetHash(rd.Hash) || changed
+	}
+	for _, s := range rd.RemovedSrcs {
+		changed = uref.RemoveSrc(s) || changed
+	}
+	for _, s := range rd.AddedSrcs {
+		changed = uref.AddSrc(s) || changed
+	}
+	for _, s := range rd.ChangedSrcs {
+		changed = uref.AddSrc(s) || changed
+	}
+	for _, d := range rd.RemovedDsts {
+		changed = uref.RemoveDst(d) || changed
+	}
+	for _, d := range rd.AddedDsts {
+		changed = uref.AddDst(d) || changed
+	}
+	for _, d := range rd.ChangedDsts {
+		changed = uref.AddDst(d) || changed
+	}
+	i.indexRef(uref)
+	return changed
+}
+
+// setAliases replaces the ref's Aliases. It returns true if the URef was
+// modified.
+func (r *URef) setAliases(hashes []data.Hash) bool {
+	var aliases []data.Hash
+	for _, a := range hashes {
+		if a.IsZero() || a.Equal(r.Hash) || hashIndex(aliases, a) >= 0 {
+			continue
+		}
+		aliases = append(aliases, a)
+	}
+	if equalHashes(r.Aliases, aliases) {
+		return false
+	}
+	r.Aliases = aliases
+	return true
+}
+
+func hashIndex(hashes []data.Hash, hash data.Hash) int {
+	for n, h := range hashes {
+		if h.Equal(hash) {
+			return n
+		}
+	}
+	return -1
+}
+
+func (i *Index) replaceSrc(src Src) bool {
+	for n, s := range i.Srcs {
+		if s.SrcID == src.SrcID {
+			if s.SrcURI.Equal(src.SrcURI) {
+				return false
+			}
+			i.Srcs[n] = src
+			return true
+		}
+	}
+	return i.AddSrc(src)
+}
+
+func (i *Index) replaceDst(dst Dst) bool {
+	for n, d := range i.Dsts {
+		if d.DstID == dst.DstID {
+			if d.IndexURI.Equal(dst.IndexURI) && d.DataURI.Equal(dst.DataURI) && d.MetaURI.Equal(dst.MetaURI) {
+				return false
+			}
+			i.Dsts[n] = dst
+			return true
+		}
+	}
+	return i.AddDst(dst)
+}
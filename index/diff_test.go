@@ -0,0 +1,157 @@
+package index
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/uri"
+)
+
+func newDiffTestIndexes() (*Index, *Index) {
+	var (
+		t1 = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+		t2 = time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	)
+	from := &Index{
+		Version: Version,
+		Srcs:    []Src{{SrcID: SrcID("s1")}, {SrcID: SrcID("s2")}},
+		Dsts:    []Dst{{DstID: DstID("d1")}},
+		Refs: []*URef{
+			{
+				Hash: data.LiteralHash("a"),
+				Srcs: []SrcItem{
+					{SrcID: SrcID("s1"), DataURI: uri.TrustedNew("a"), ModifiedAt: t1},
+					{SrcID: SrcID("s2"), DataURI: uri.TrustedNew("a")},
+				},
+				Dsts: []DstItem{
+					{DstID: DstID("d1"), DataURI: uri.TrustedNew("a"), UpdatedAt: t1},
+				},
+			},
+			{
+				Hash: data.LiteralHash("b"),
+				Dsts: []DstItem{{DstID: DstID("d1"), DataURI: uri.TrustedNew("b")}},
+			},
+			{
+				Hash: data.LiteralHash("c"),
+				Dsts: []DstItem{{DstID: DstID("d1"), DataURI: uri.TrustedNew("c")}},
+			},
+		},
+	}
+	to := &Index{
+		Version: Version,
+		Srcs:    []Src{{SrcID: SrcID("s1")}, {SrcID: SrcID("s3")}},
+		Dsts:    []Dst{{DstID: DstID("d1")}},
+		Refs: []*URef{
+			{
+				Hash: data.LiteralHash("a"),
+				Srcs: []SrcItem{
+					{SrcID: SrcID("s1"), DataURI: uri.TrustedNew("a"), ModifiedAt: t2},
+					{SrcID: SrcID("s3"), DataURI: uri.TrustedNew("a")},
+				},
+				Dsts: []DstItem{
+					{DstID: DstID("d1"), DataURI: uri.TrustedNew("a"), UpdatedAt: t1},
+				},
+			},
+			{
+				Hash:    data.LiteralHash("b2"),
+				Aliases: []data.Hash{data.LiteralHash("b")},
+				Dsts:    []DstItem{{DstID: DstID("d1"), DataURI: uri.TrustedNew("b")}},
+			},
+			{
+				Hash: data.LiteralHash("d"),
+				Dsts: []DstItem{{DstID: DstID("d1"), DataURI: uri.TrustedNew("d")}},
+			},
+		},
+	}
+	return from, to
+}
+
+func TestDiff(t *testing.T) {
+	from, to := newDiffTestIndexes()
+	got := Diff(from, to)
+	want := &Changes{
+		AddedSrcs:   []Src{{SrcID: SrcID("s3")}},
+		RemovedSrcs: []Src{{SrcID: SrcID("s2")}},
+		AddedRefs:   []*URef{to.Refs[2]},
+		RemovedRefs: []data.Hash{data.LiteralHash("c")},
+		ChangedRefs: []RefChanges{
+			{
+				Hash:        data.LiteralHash("a"),
+				AddedSrcs:   []SrcItem{{SrcID: SrcID("s3"), DataURI: uri.TrustedNew("a")}},
+				RemovedSrcs: []SrcItem{{SrcID: SrcID("s2"), DataURI: uri.TrustedNew("a")}},
+				ChangedSrcs: []SrcItem{to.Refs[0].Srcs[0]},
+			},
+			{
+				Hash:        data.LiteralHash("b2"),
+				HashChanged: true,
+				Aliases:     []data.Hash{data.LiteralHash("b")},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff()\ngot  %#v\nwant %#v", got, want)
+	}
+	if got.IsEmpty() {
+		t.Errorf("IsEmpty() must be false")
+	}
+	if d := Diff(to, to); !d.IsEmpty() {
+		t.Errorf("Diff() of the same index must be empty, got %#v", d)
+	}
+}
+
+func TestApply(t *testing.T) {
+	from, to := newDiffTestIndexes()
+
+	// Send the changes as JSON, as they would be to another machine.
+	b, err := json.Marshal(Diff(from, to))
+	if err != nil {
+		t.Fatalf("failed to marshal: %s", err)
+	}
+	var changes Changes
+	if err := json.Unmarshal(b, &changes); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+
+	if !from.Apply(&changes) {
+		t.Errorf("Apply() must modify")
+	}
+	if d := Diff(from, to); !d.IsEmpty() {
+		t.Errorf("Apply() result differs from target: %#v", d)
+	}
+	if from.Apply(&changes) {
+		t.Errorf("Apply() must be idempotent")
+	}
+	if _, ok := from.GetRef(data.LiteralHash("b")); !ok {
+		t.Errorf("GetRef(b) must find the ref by alias")
+	}
+	if refs := from.FindBySrcDataURI(uri.TrustedNew("a")); len(refs) != 1 {
+		t.Errorf("FindBySrcDataURI(a) got %d refs want 1", len(refs))
+	}
+}
+
+func TestApplyHashChange(t *testing.T) {
+	from := New()
+	from.Refs = []*URef{{Hash: data.LiteralHash("a"), Aliases: []data.Hash{data.LiteralHash("b")}}}
+	to := New()
+	to.Refs = []*URef{{Hash: data.LiteralHash("b")}}
+
+	changes := Diff(from, to)
+	if changes.IsEmpty() {
+		t.Fatalf("Diff() must include a change of hash")
+	}
+	if !from.Apply(changes) {
+		t.Errorf("Apply() must modify")
+	}
+	if d := Diff(from, to); !d.IsEmpty() {
+		t.Errorf("Apply() result differs from target: %#v", d)
+	}
+	if _, ok := from.GetRef(data.LiteralHash("a")); ok {
+		t.Errorf("GetRef(a) must not find the removed alias")
+	}
+	if _, ok := from.GetRef(data.LiteralHash("b")); !ok {
+		t.Errorf("GetRef(b) must find the ref")
+	}
+}
@@ -114,6 +114,11 @@ type DstItem struct {
 	// UpdatedAt is the time that the item was updated. This typically
 	// means metadata updates since data is immutable.
 	UpdatedAt time.Time
+
+	// VerifiedAt is the last time that the stored data was read and found
+	// to match its hash, such as by Scrub. It's zero if it has never been
+	// verified.
+	VerifiedAt time.Time
 }
 
 // EqualKey determines if two DstItem have the same primary key.
@@ -138,6 +143,7 @@ func (d DstItem) Equal(dd DstItem) bool {
 	case d.MetaSize != dd.MetaSize:
 	case !d.StoredAt.Equal(dd.StoredAt):
 	case !d.UpdatedAt.Equal(dd.UpdatedAt):
+	case !d.VerifiedAt.Equal(dd.VerifiedAt):
 	default:
 		return true
 	}
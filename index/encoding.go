@@ -111,14 +111,15 @@ func (s *Dst) UnmarshalJSON(data []byte) error {
 }
 
 type dstItemJSON struct {
-	DstID     DstID      `json:"dst_id"`
-	DataURI   uri.URI    `json:"data_uri"`
-	MetaURI   uri.URI    `json:"meta_uri"`
-	DataType  string     `json:"data_type,omitempty"`
-	DataSize  int64      `json:"data_size,omitempty"`
-	MetaSize  int64      `json:"meta_size,omitempty"`
-	StoredAt  *time.Time `json:"stored_at,omitempty"`
-	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	DstID      DstID      `json:"dst_id"`
+	DataURI    uri.URI    `json:"data_uri"`
+	MetaURI    uri.URI    `json:"meta_uri"`
+	DataType   string     `json:"data_type,omitempty"`
+	DataSize   int64      `json:"data_size,omitempty"`
+	MetaSize   int64      `json:"meta_size,omitempty"`
+	StoredAt   *time.Time `json:"stored_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
 }
 
 // MarshalJSON converts DstItem to JSON.
@@ -137,6 +138,9 @@ func (d DstItem) MarshalJSON() ([]byte, error) {
 	if !d.UpdatedAt.IsZero() {
 		j.UpdatedAt = &d.UpdatedAt
 	}
+	if !d.VerifiedAt.IsZero() {
+		j.VerifiedAt = &d.VerifiedAt
+	}
 	return json.Marshal(j)
 }
 
@@ -162,19 +166,24 @@ func (d *DstItem) UnmarshalJSON(b []byte) error {
 	if dj.UpdatedAt != nil {
 		d.UpdatedAt = *dj.UpdatedAt
 	}
+	if dj.VerifiedAt != nil {
+		d.VerifiedAt = *dj.VerifiedAt
+	}
 	return nil
 }
 
 type uRefJSON struct {
-	Hash data.Hash `json:"hash"`
-	Srcs []SrcItem `json:"srcs"`
-	Dsts []DstItem `json:"dsts"`
+	Hash    data.Hash   `json:"hash"`
+	Aliases []data.Hash `json:"aliases,omitempty"`
+	Srcs    []SrcItem   `json:"srcs"`
+	Dsts    []DstItem   `json:"dsts"`
 }
 
 // MarshalJSON implements json.Marshaler.
 func (r URef) MarshalJSON() ([]byte, error) {
 	var rj uRefJSON
 	rj.Hash = r.Hash
+	rj.Aliases = r.Aliases
 	if len(r.Srcs) == 0 {
 		rj.Srcs = make([]SrcItem, 0)
 	} else {
@@ -195,6 +204,9 @@ func (r *URef) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	r.Hash = rj.Hash
+	if len(rj.Aliases) > 0 {
+		r.Aliases = rj.Aliases
+	}
 	if len(rj.Srcs) > 0 {
 		r.Srcs = rj.Srcs
 	}
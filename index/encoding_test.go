@@ -162,6 +162,15 @@ func TestDstItemJSON(t *testing.T) {
 			},
 			json: `{"dst_id":"abc","data_uri":"http://example.com/data/abc.jpg","meta_uri":"http://example.com/meta/abc.json","data_type":"jpg","data_size":100,"meta_size":10,"stored_at":"0001-02-03T04:05:06.000000007Z","updated_at":"0002-02-03T04:05:06.000000007Z"}`,
 		},
+		{
+			desc: "verified",
+			item: DstItem{
+				DstID:      DstID("abc"),
+				DataURI:    uri.TrustedNew("http://example.com/data/abc.jpg"),
+				VerifiedAt: time.Date(3, 2, 3, 4, 5, 6, 7, time.UTC),
+			},
+			json: `{"dst_id":"abc","data_uri":"http://example.com/data/abc.jpg","meta_uri":"","verified_at":"0003-02-03T04:05:06.000000007Z"}`,
+		},
 	}
 	for _, tt := range tests {
 		data, err := json.Marshal(tt.item)
@@ -222,6 +231,14 @@ func TestURefJSON(t *testing.T) {
 			},
 			json: `{"hash":"xyz","srcs":[{"src_id":"a","data_uri":"http://example.com/data.jpg","meta_uri":"http://example.com/meta.json","modified_at":"2015-02-03T04:05:06.000000007Z"}],"dsts":[{"dst_id":"abc","data_uri":"http://example.com/data/abc.jpg","meta_uri":"http://example.com/meta/abc.json","data_type":"jpg","data_size":100,"meta_size":10,"stored_at":"0001-02-03T04:05:06.000000007Z","updated_at":"0002-02-03T04:05:06.000000007Z"}]}`,
 		},
+		{
+			desc: "aliases",
+			ref: URef{
+				Hash:    data.LiteralHash("xyz"),
+				Aliases: []data.Hash{data.LiteralHash("abc")},
+			},
+			json: `{"hash":"xyz","aliases":["abc"],"srcs":[],"dsts":[]}`,
+		},
 	}
 	for _, tt := range tests {
 		data, err := json.Marshal(tt.ref)
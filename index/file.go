@@ -0,0 +1,257 @@
+package index
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// File keeps an Index in a file, such as index.json, so that a crash never
+// leaves it unreadable.
+//
+// Save writes the whole index to a temporary file, syncs it and renames it
+// into place, so the file is always either the previous or the new version.
+// The previous versions are kept as numbered backups, index.json.1 being the
+// most recent.
+//
+// Changes made between saves are appended to a journal, index.json.journal,
+// which is replayed when the file is opened and emptied by Save. It's an index
+// log without a header; see LogWriter.
+//
+// If the index can't be read, for example because it was written partially by
+// a program that doesn't use File, the most recent backup that can be read is
+// used instead.
+//
+// It's not safe for concurrent use.
+type File struct {
+	path      string
+	backups   int
+	idx       *Index
+	journal   *os.File
+	log       *LogWriter
+	recovered string
+}
+
+// OpenFile loads the index at path along with its journal, keeping up to
+// backups previous versions when it's saved. If neither the index nor any
+// backups exist a new Index is started.
+func OpenFile(path string, backups int) (*File, error) {
+	f := &File{path: path, backups: backups}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	if err := f.openJournal(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Index returns the index. Changes made to it directly are only written by
+// Save; use the File's methods to have them journaled.
+func (f *File) Index() *Index {
+	return f.idx
+}
+
+// Recovered returns the path of the backup that the index was loaded from if
+// the index itself couldn't be read. It's empty if the index was read, or once
+// it has been saved.
+func (f *File) Recovered() string {
+	return f.recovered
+}
+
+// AddSrc adds a source to the index and journals it. It's idempotent,
+// returning true if the index was modified.
+func (f *File) AddSrc(src Src) (bool, error) {
+	if !f.idx.AddSrc(src) {
+		return false, nil
+	}
+	return true, f.sync(f.log.AddSrc(src))
+}
+
+// AddDst adds a destination to the index and journals it. It's idempotent,
+// returning true if the index was modified.
+func (f *File) AddDst(dst Dst) (bool, error) {
+	if !f.idx.AddDst(dst) {
+		return false, nil
+	}
+	return true, f.sync(f.log.AddDst(dst))
+}
+
+// AddRef adds a ref to the index and journals it. It's idempotent, returning
+// true if the index was modified.
+func (f *File) AddRef(ref Ref) (bool, error) {
+	if !f.idx.AddRef(ref) {
+		return false, nil
+	}
+	return true, f.sync(f.log.AddRef(ref))
+}
+
+// Save writes the whole index, rotating the backups, and empties the journal.
+func (f *File) Save() error {
+	b, err := json.Marshal(f.idx)
+	if err != nil {
+		return err
+	}
+	// A backup is only made of an index that could be read, so that good
+	// backups are never pushed out by a bad one.
+	backups := f.backups
+	if f.recovered != "" {
+		backups = 0
+	}
+	if err := writeFileAtomic(f.path, b, backups); err != nil {
+		return fmt.Errorf("index: save %s: %s", f.path, err)
+	}
+	f.recovered = ""
+	if err := f.journal.Truncate(0); err != nil {
+		return fmt.Errorf("index: save %s: %s", f.path, err)
+	}
+	return f.sync(nil)
+}
+
+// Close closes the journal. Changes that haven't been saved remain in it.
+func (f *File) Close() error {
+	return f.journal.Close()
+}
+
+func (f *File) journalPath() string {
+	return f.path + ".journal"
+}
+
+func (f *File) load() error {
+	paths := []string{f.path}
+	for n := 1; n <= f.backups; n++ {
+		paths = append(paths, backupPath(f.path, n))
+	}
+	var first error
+	for _, path := range paths {
+		idx, err := loadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			if first == nil {
+				first = fmt.Errorf("index: load %s: %s", path, err)
+			}
+			continue
+		}
+		f.idx = idx
+		if first != nil {
+			f.recovered = path
+		}
+		return nil
+	}
+	if first != nil {
+		return first
+	}
+	f.idx = New()
+	return nil
+}
+
+func loadFile(path string) (*Index, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+	return ParseJSON(fi)
+}
+
+// openJournal replays the journal and opens it for appending. A partially
+// written event at its end is repaired so that the next one starts on its own
+// line; see AppendLog.
+func (f *File) openJournal() error {
+	j, err := os.OpenFile(f.journalPath(), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	size, complete, err := f.idx.replayLog(bufio.NewReader(j), 1)
+	if err == nil {
+		err = repairLog(j, size, complete)
+	}
+	if err != nil {
+		j.Close()
+		return fmt.Errorf("index: journal %s: %s", f.journalPath(), err)
+	}
+	f.journal = j
+	f.log = NewLogWriter(j)
+	return nil
+}
+
+// sync flushes the journal to disk if err is nil, which is the result of
+// writing to it.
+func (f *File) sync(err error) error {
+	if err == nil {
+		err = f.journal.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("index: journal %s: %s", f.journalPath(), err)
+	}
+	return nil
+}
+
+func backupPath(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// writeFileAtomic writes b to path so that path is never left partially
+// written: b is written to a temporary file in the same directory, synced and
+// renamed to path. If backups is more than zero, up to that many previous
+// versions are kept as path.1, path.2 and so on.
+func writeFileAtomic(path string, b []byte, backups int) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	if backups > 0 {
+		for n := backups; n > 1; n-- {
+			err := os.Rename(backupPath(path, n-1), backupPath(path, n))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		// Between these renames path doesn't exist. A reader then
+		// falls back to the backup, which is the same version.
+		err := os.Rename(path, backupPath(path, 1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes renames in dir durable. Not every platform supports syncing a
+// directory, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
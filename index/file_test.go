@@ -0,0 +1,217 @@
+package index_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/index"
+	"github.com/recentralized/structure/uri"
+)
+
+func fileRef(hash string) index.Ref {
+	return index.Ref{
+		Hash: data.LiteralHash(hash),
+		Src:  index.SrcItem{SrcID: index.SrcID("s1"), DataURI: uri.TrustedNew(hash)},
+		Dst:  index.DstItem{DstID: index.DstID("d1"), DataURI: uri.TrustedNew(hash)},
+	}
+}
+
+func openFile(t *testing.T, path string, backups int) *index.File {
+	t.Helper()
+	f, err := index.OpenFile(path, backups)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %s", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func addRefs(t *testing.T, f *index.File, hashes ...string) {
+	t.Helper()
+	for _, h := range hashes {
+		if _, err := f.AddRef(fileRef(h)); err != nil {
+			t.Fatalf("AddRef(%s) failed: %s", h, err)
+		}
+	}
+}
+
+func checkRefs(t *testing.T, desc string, idx *index.Index, hashes ...string) {
+	t.Helper()
+	if got, want := len(idx.Refs), len(hashes); got != want {
+		t.Errorf("%s got %d refs want %d", desc, got, want)
+	}
+	for _, h := range hashes {
+		if _, ok := idx.GetRef(data.LiteralHash(h)); !ok {
+			t.Errorf("%s missing ref %s", desc, h)
+		}
+	}
+}
+
+func TestFileJournal(t *testing.T) {
+	path := filepath.Join(tempDir(t), "index.json")
+	f := openFile(t, path, 0)
+	if ok, err := f.AddSrc(index.Src{SrcID: index.SrcID("s1")}); !ok || err != nil {
+		t.Fatalf("AddSrc() got %t, %v want true", ok, err)
+	}
+	addRefs(t, f, "a", "b")
+	if ok, err := f.AddRef(fileRef("a")); ok || err != nil {
+		t.Errorf("AddRef(a) again got %t, %v want false", ok, err)
+	}
+	f.Close()
+
+	// Nothing was saved; everything comes from the journal.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("index must not be written before Save(): %v", err)
+	}
+	f = openFile(t, path, 0)
+	checkRefs(t, "journal", f.Index(), "a", "b")
+	if _, ok := f.Index().GetSrc(index.SrcID("s1")); !ok {
+		t.Errorf("journal missing src")
+	}
+
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save() failed: %s", err)
+	}
+	if fi, err := os.Stat(path + ".journal"); err != nil || fi.Size() != 0 {
+		t.Errorf("journal must be empty after Save(): %v", err)
+	}
+	addRefs(t, f, "c")
+	f.Close()
+
+	f = openFile(t, path, 0)
+	checkRefs(t, "saved and journal", f.Index(), "a", "b", "c")
+
+	// A partially written event is ignored, and cut off so that the next
+	// one can be read.
+	j, err := os.OpenFile(path+".journal", os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("failed to open journal: %s", err)
+	}
+	j.WriteString(`{"ref":{"hash":"d","sr`)
+	j.Close()
+	f.Close()
+	f = openFile(t, path, 0)
+	checkRefs(t, "torn journal", f.Index(), "a", "b", "c")
+	addRefs(t, f, "e")
+	f.Close()
+	f = openFile(t, path, 0)
+	checkRefs(t, "after torn journal", f.Index(), "a", "b", "c", "e")
+
+	files, _ := ioutil.ReadDir(filepath.Dir(path))
+	if got, want := len(files), 2; got != want {
+		t.Errorf("got %d files want index and journal", got)
+	}
+}
+
+func TestFileJournalMissingNewline(t *testing.T) {
+	path := filepath.Join(tempDir(t), "index.json")
+	f := openFile(t, path, 0)
+	addRefs(t, f, "a")
+	f.Close()
+
+	// The event is complete but for its newline.
+	jpath := path + ".journal"
+	b, err := ioutil.ReadFile(jpath)
+	if err != nil {
+		t.Fatalf("failed to read journal: %s", err)
+	}
+	if err := ioutil.WriteFile(jpath, b[:len(b)-1], 0644); err != nil {
+		t.Fatalf("failed to write journal: %s", err)
+	}
+
+	// It's kept each time the file is opened, without saving.
+	for n := 1; n <= 2; n++ {
+		f = openFile(t, path, 0)
+		checkRefs(t, "missing newline", f.Index(), "a")
+		f.Close()
+	}
+	f = openFile(t, path, 0)
+	addRefs(t, f, "b")
+	f.Close()
+	f = openFile(t, path, 0)
+	checkRefs(t, "after missing newline", f.Index(), "a", "b")
+}
+
+func TestFileBackups(t *testing.T) {
+	path := filepath.Join(tempDir(t), "index.json")
+	f := openFile(t, path, 2)
+	for _, h := range []string{"a", "b", "c", "d"} {
+		addRefs(t, f, h)
+		if err := f.Save(); err != nil {
+			t.Fatalf("Save() failed: %s", err)
+		}
+	}
+	tests := []struct {
+		path   string
+		hashes []string
+	}{
+		{path, []string{"a", "b", "c", "d"}},
+		{path + ".1", []string{"a", "b", "c"}},
+		{path + ".2", []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		fi, err := os.Open(tt.path)
+		if err != nil {
+			t.Fatalf("failed to open %s: %s", tt.path, err)
+		}
+		idx, err := index.ParseJSON(fi)
+		fi.Close()
+		if err != nil {
+			t.Fatalf("%s is not an index: %s", tt.path, err)
+		}
+		checkRefs(t, tt.path, idx, tt.hashes...)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("only 2 backups must be kept")
+	}
+}
+
+func TestFileTornWrite(t *testing.T) {
+	path := filepath.Join(tempDir(t), "index.json")
+	f := openFile(t, path, 2)
+	addRefs(t, f, "a")
+	f.Save()
+	addRefs(t, f, "b")
+	f.Save()
+	addRefs(t, f, "c")
+	f.Close()
+
+	// Simulate a program writing the index in place and dying.
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read index: %s", err)
+	}
+	if err := ioutil.WriteFile(path, b[:len(b)/2], 0644); err != nil {
+		t.Fatalf("failed to tear index: %s", err)
+	}
+
+	f = openFile(t, path, 2)
+	if got, want := f.Recovered(), path+".1"; got != want {
+		t.Errorf("Recovered() got %q want %q", got, want)
+	}
+	// The backup has a, and the journal adds c. Only b was lost.
+	checkRefs(t, "recovered", f.Index(), "a", "c")
+
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save() failed: %s", err)
+	}
+	if got := f.Recovered(); got != "" {
+		t.Errorf("Recovered() after Save() got %q want none", got)
+	}
+	f.Close()
+	f = openFile(t, path, 2)
+	checkRefs(t, "saved", f.Index(), "a", "c")
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Errorf("torn index must not be kept as a backup")
+	}
+
+	// With no readable copy, opening fails rather than starting over.
+	ioutil.WriteFile(path, []byte("{"), 0644)
+	ioutil.WriteFile(path+".1", []byte("{"), 0644)
+	if _, err := index.OpenFile(path, 2); err == nil {
+		t.Errorf("OpenFile() with no readable copy must fail")
+	}
+}
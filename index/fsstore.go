@@ -0,0 +1,379 @@
+package index
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/dst"
+	"github.com/recentralized/structure/uri"
+)
+
+// fsIndexJSON is the index document written by the filesystem store. Without
+// RefsURIs it's identical to an Index.
+type fsIndexJSON struct {
+	Version  string    `json:"version"`
+	Srcs     []Src     `json:"srcs,omitempty"`
+	Dsts     []Dst     `json:"dsts,omitempty"`
+	RefsURIs []uri.URI `json:"refs_uris,omitempty"`
+	Refs     []*URef   `json:"refs,omitempty"`
+}
+
+// NewFilesystemStore initializes a Store that keeps the index in files under
+// dir, at the locations given by layout. Srcs and Dsts are stored at the
+// layout's IndexURI, and each ref at its RefsURI. If refs are stored apart
+// from the index, the index lists those documents so they can be found again.
+//
+// The index is loaded into memory, and each change is durable before
+// returning. Adding a ref or alias appends it to a journal next to the index,
+// an index log without a header; see LogWriter. The documents the journal affects are
+// rewritten once it holds as many refs as half the index, so that building a
+// large index doesn't rewrite it for every ref. Other changes are written to
+// the documents directly. A journal left by a process that died is replayed
+// and written out when the store is opened. It's not safe for concurrent use.
+func NewFilesystemStore(dir string, layout dst.Layout) (Store, error) {
+	s := &fsStore{
+		memoryStore: memoryStore{New()},
+		dir:         dir,
+		layout:      layout,
+		shards:      make(map[string]bool),
+		dirty:       make(map[string]bool),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type fsStore struct {
+	memoryStore
+	dir    string
+	layout dst.Layout
+	shards map[string]bool
+
+	// dirty is the documents with changes that are only in the journal,
+	// which has pending events.
+	dirty   map[string]bool
+	pending int
+}
+
+// compactMin is the fewest journal events that are compacted, so that small
+// indexes aren't rewritten for every ref either.
+const compactMin = 1000
+
+func (s *fsStore) AddSrc(ctx context.Context, src Src) (bool, error) {
+	ok, err := s.memoryStore.AddSrc(ctx, src)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, s.writeIndex()
+}
+
+func (s *fsStore) RemoveSrc(ctx context.Context, srcID SrcID) (bool, error) {
+	if err := s.compact(); err != nil {
+		return false, err
+	}
+	ok, err := s.memoryStore.RemoveSrc(ctx, srcID)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, s.writeAll()
+}
+
+func (s *fsStore) AddDst(ctx context.Context, dst Dst) (bool, error) {
+	ok, err := s.memoryStore.AddDst(ctx, dst)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, s.writeIndex()
+}
+
+func (s *fsStore) RemoveDst(ctx context.Context, dstID DstID, orphans OrphanPolicy) (bool, error) {
+	if err := s.compact(); err != nil {
+		return false, err
+	}
+	ok, err := s.memoryStore.RemoveDst(ctx, dstID, orphans)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, s.writeAll()
+}
+
+func (s *fsStore) AddRef(ctx context.Context, ref Ref) (bool, error) {
+	shard := s.shardOf(ref.Hash)
+	ok, err := s.memoryStore.AddRef(ctx, ref)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, s.journalRef(shard, logEvent{Ref: &logRef{ref.Hash, ref.Src, ref.Dst}})
+}
+
+// journalRef journals ev, which changes a ref in shard, compacting the
+// journal once it's large enough.
+func (s *fsStore) journalRef(shard uri.URI, ev logEvent) error {
+	s.dirty[shard.String()] = true
+	if err := s.journal(ev); err != nil {
+		return err
+	}
+	if s.pending >= compactMin && s.pending >= len(s.idx.Refs)/2 {
+		return s.compact()
+	}
+	return nil
+}
+
+func (s *fsStore) AddAlias(ctx context.Context, hash, alias data.Hash) (bool, error) {
+	ok, err := s.memoryStore.AddAlias(ctx, hash, alias)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, s.journalRef(s.shardOf(hash), logEvent{Alias: &logAlias{hash, alias}})
+}
+
+func (s *fsStore) RemoveRef(ctx context.Context, hash data.Hash) (bool, error) {
+	uref, ok := s.idx.GetRef(hash)
+	if !ok {
+		return false, ctx.Err()
+	}
+	// Write out the journal first, so that replaying it can't add the
+	// ref back.
+	if err := s.compact(); err != nil {
+		return false, err
+	}
+	shard := s.shardOf(uref.Hash)
+	ok, err := s.memoryStore.RemoveRef(ctx, hash)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, s.writeShard(shard)
+}
+
+// shardOf returns the document that a ref with hash is stored in. If it's a
+// new document it's recorded in the index.
+func (s *fsStore) shardOf(hash data.Hash) uri.URI {
+	if uref, ok := s.idx.GetRef(hash); ok {
+		hash = uref.Hash
+	}
+	return s.layout.RefsURI(hash)
+}
+
+func (s *fsStore) isIndex(u uri.URI) bool {
+	return u.Equal(s.layout.IndexURI())
+}
+
+func (s *fsStore) path(u uri.URI) string {
+	return filepath.Join(s.dir, filepath.FromSlash(u.String()))
+}
+
+func (s *fsStore) journalPath() string {
+	return s.path(s.layout.IndexURI()) + ".journal"
+}
+
+// journal appends ev to the journal and syncs it.
+func (s *fsStore) journal(ev logEvent) error {
+	f, err := os.OpenFile(s.journalPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	err = NewLogWriter(f).write(ev)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("index: journal %s: %s", s.journalPath(), err)
+	}
+	s.pending++
+	return nil
+}
+
+// compact writes the documents with journaled changes and removes the
+// journal.
+func (s *fsStore) compact() error {
+	if s.pending == 0 {
+		return nil
+	}
+	indexKey := s.layout.IndexURI().String()
+	refs := make(map[string][]*URef)
+	for _, uref := range s.idx.Refs {
+		key := s.layout.RefsURI(uref.Hash).String()
+		if s.dirty[key] {
+			refs[key] = append(refs[key], uref)
+		}
+	}
+	writeIndex := s.dirty[indexKey]
+	for key := range s.dirty {
+		if key == indexKey {
+			continue
+		}
+		if !s.shards[key] {
+			s.shards[key] = true
+			writeIndex = true
+		}
+		doc := &Index{Version: s.idx.Version, Refs: refs[key]}
+		if err := s.writeJSON(uri.TrustedNew(key), doc); err != nil {
+			return err
+		}
+	}
+	// The index is written after any new documents that it lists.
+	if writeIndex {
+		if err := s.writeIndexRefs(refs[indexKey]); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(s.journalPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.dirty = make(map[string]bool)
+	s.pending = 0
+	return nil
+}
+
+func (s *fsStore) load() error {
+	if err := s.loadIndex(); err != nil {
+		return err
+	}
+	return s.loadJournal()
+}
+
+// loadJournal replays a journal left by a process that didn't compact it,
+// and compacts it.
+func (s *fsStore) loadJournal() error {
+	f, err := os.Open(s.journalPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, _, err := s.idx.replayLog(bufio.NewReader(f), 1); err != nil {
+		return fmt.Errorf("index: journal %s: %s", s.journalPath(), err)
+	}
+	// Which documents were changed isn't known, so all are written.
+	for _, uref := range s.idx.Refs {
+		s.dirty[s.layout.RefsURI(uref.Hash).String()] = true
+	}
+	s.pending++
+	return s.compact()
+}
+
+func (s *fsStore) loadIndex() error {
+	f, err := os.Open(s.path(s.layout.IndexURI()))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var doc fsIndexJSON
+	if err := json.NewDecoder(f).Decode(&doc); err != nil {
+		return err
+	}
+	if _, err := upgradeVersion(doc.Version); err != nil {
+		return err
+	}
+	s.idx.Srcs = doc.Srcs
+	s.idx.Dsts = doc.Dsts
+	s.idx.Refs = doc.Refs
+	for _, u := range doc.RefsURIs {
+		s.shards[u.String()] = true
+		if err := s.loadShard(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fsStore) loadShard(u uri.URI) error {
+	f, err := os.Open(s.path(u))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	shard, err := ParseJSON(f)
+	if err != nil {
+		return err
+	}
+	s.idx.Refs = append(s.idx.Refs, shard.Refs...)
+	return nil
+}
+
+// refsIn returns the refs that are stored in the document u.
+func (s *fsStore) refsIn(u uri.URI) []*URef {
+	var refs []*URef
+	for _, uref := range s.idx.Refs {
+		if s.layout.RefsURI(uref.Hash).Equal(u) {
+			refs = append(refs, uref)
+		}
+	}
+	return refs
+}
+
+func (s *fsStore) writeAll() error {
+	if err := s.writeIndex(); err != nil {
+		return err
+	}
+	for key := range s.shards {
+		if err := s.writeShard(uri.TrustedNew(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fsStore) writeIndex() error {
+	return s.writeIndexRefs(s.refsIn(s.layout.IndexURI()))
+}
+
+// writeIndexRefs writes the index document, which stores refs.
+func (s *fsStore) writeIndexRefs(refs []*URef) error {
+	indexURI := s.layout.IndexURI()
+	doc := fsIndexJSON{
+		Version: s.idx.Version,
+		Srcs:    s.idx.Srcs,
+		Dsts:    s.idx.Dsts,
+		Refs:    refs,
+	}
+	var keys []string
+	for key := range s.shards {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		doc.RefsURIs = append(doc.RefsURIs, uri.TrustedNew(key))
+	}
+	return s.writeJSON(indexURI, doc)
+}
+
+func (s *fsStore) writeShard(u uri.URI) error {
+	if s.isIndex(u) {
+		return s.writeIndex()
+	}
+	if !s.shards[u.String()] {
+		s.shards[u.String()] = true
+		if err := s.writeIndex(); err != nil {
+			return err
+		}
+	}
+	doc := &Index{Version: s.idx.Version, Refs: s.refsIn(u)}
+	return s.writeJSON(u, doc)
+}
+
+func (s *fsStore) writeJSON(u uri.URI, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(u), b, 0)
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 
 	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/uri"
 )
 
 // Version identifies the version of the index structure. A new version will be
@@ -29,12 +30,15 @@ var ErrWrongVersion = errors.New("index is not at a compatible version")
 // The methods it provides are convenience, suitable for small in-memory
 // implementations. Since index could be implemented in any number of ways,
 // such as a relational dataase, the methods here serve as documentation of the
-// algorithms to add and retrieve data.
+// algorithms to add and retrieve data. Lookups by SrcID, DstID and Hash use
+// maps, so adding to a large index stays fast.
 type Index struct {
 	Version string  `json:"version"`
 	Srcs    []Src   `json:"srcs,omitempty"`
 	Dsts    []Dst   `json:"dsts,omitempty"`
 	Refs    []*URef `json:"refs,omitempty"`
+
+	lookup lookup
 }
 
 // New initializes a new Index at the current version.
@@ -51,12 +55,9 @@ func ParseJSON(r io.Reader) (*Index, error) {
 	if err != nil {
 		return nil, err
 	}
-	switch idx.Version {
-	case versionV1:
-	case versionV0:
-		idx.Version = versionV1
-	default:
-		return nil, ErrWrongVersion
+	idx.Version, err = upgradeVersion(idx.Version)
+	if err != nil {
+		return nil, err
 	}
 	return idx, nil
 }
@@ -64,74 +65,143 @@ func ParseJSON(r io.Reader) (*Index, error) {
 // AddSrc adds a source to the index. It's idempotent, returning true if the
 // index was modified.
 func (i *Index) AddSrc(src Src) bool {
-	for _, s := range i.Srcs {
-		if s.SrcID == src.SrcID {
-			return false
-		}
+	srcs := i.srcLookup()
+	if _, ok := srcs[src.SrcID]; ok {
+		return false
 	}
+	srcs[src.SrcID] = len(i.Srcs)
 	i.Srcs = append(i.Srcs, src)
+	i.lookup.srcsShape = shapeOfSrcs(i.Srcs)
 	return true
 }
 
 // AddDst adds a destination to the index. It's idempotent, returning true if
 // the index was modified.
 func (i *Index) AddDst(dst Dst) bool {
-	for _, d := range i.Dsts {
-		if d.DstID == dst.DstID {
-			return false
-		}
+	dsts := i.dstLookup()
+	if _, ok := dsts[dst.DstID]; ok {
+		return false
 	}
+	dsts[dst.DstID] = len(i.Dsts)
 	i.Dsts = append(i.Dsts, dst)
+	i.lookup.dstsShape = shapeOfDsts(i.Dsts)
 	return true
 }
 
 // GetSrc returns the source with srcID. It returns false if no source was
 // found.
 func (i *Index) GetSrc(srcID SrcID) (Src, bool) {
-	for _, src := range i.Srcs {
-		if src.SrcID == srcID {
-			return src, true
-		}
+	n, ok := i.srcLookup()[srcID]
+	if !ok || i.Srcs[n].SrcID != srcID {
+		return Src{}, false
 	}
-	return Src{}, false
+	return i.Srcs[n], true
 }
 
 // GetDst returns the destination with dstID. It returns false if no
 // destination was found.
 func (i *Index) GetDst(dstID DstID) (Dst, bool) {
-	for _, dst := range i.Dsts {
-		if dst.DstID == dstID {
-			return dst, true
-		}
+	n, ok := i.dstLookup()[dstID]
+	if !ok || i.Dsts[n].DstID != dstID {
+		return Dst{}, false
 	}
-	return Dst{}, false
+	return i.Dsts[n], true
 }
 
 // AddRef adds a ref to the index. A ref is a hash with source and destination.
 // It's idempotent, returning true if the index was modified.
 func (i *Index) AddRef(ref Ref) bool {
-	var uref *URef
-	for _, u := range i.Refs {
-		if u.Hash.Equal(ref.Hash) {
-			uref = u
-			break
-		}
-	}
-	if uref == nil {
+	uref, ok := i.findRef(ref.Hash)
+	if !ok {
 		uref = &URef{Hash: ref.Hash}
 		i.Refs = append(i.Refs, uref)
+		i.indexRef(uref)
+		i.lookup.refsShape = shapeOfRefs(i.Refs)
 	}
 	addSrc := uref.AddSrc(ref.Src)
 	addDst := uref.AddDst(ref.Dst)
+	i.indexSrcItem(uref, ref.Src)
+	i.indexDstItem(uref, ref.Dst)
 	return addSrc || addDst
 }
 
+// AddAlias adds alias as another hash of the ref with hash, so that it's
+// found by either. It returns false if no ref has hash, or if alias is already
+// a hash of a ref.
+func (i *Index) AddAlias(hash, alias data.Hash) bool {
+	uref, ok := i.findRef(hash)
+	if !ok || alias.IsZero() {
+		return false
+	}
+	if _, ok := i.findRef(alias); ok {
+		return false
+	}
+	uref.AddAlias(alias)
+	i.lookup.refs[alias.Key()] = uref
+	return true
+}
+
 // GetRef retrieves a URef from the index. A URef is a hash with all sources
 // and destinations that have been added. If you're only interested in one
-// source or destination see GetSrcItem and GetDstItem.
+// source or destination see GetSrcItem and GetDstItem. The hash may match
+// the URef's Hash or any of its Aliases.
 func (i *Index) GetRef(hash data.Hash) (*URef, bool) {
-	for _, uref := range i.Refs {
-		if uref.Hash.Equal(hash) {
+	return i.findRef(hash)
+}
+
+// GetSrcItem returns the item that the data with hash was found as in srcID.
+// It returns false if the data wasn't found in that source.
+func (i *Index) GetSrcItem(hash data.Hash, srcID SrcID) (SrcItem, bool) {
+	uref, ok := i.findRef(hash)
+	if !ok {
+		return SrcItem{}, false
+	}
+	for _, src := range uref.Srcs {
+		if src.SrcID == srcID {
+			return src, true
+		}
+	}
+	return SrcItem{}, false
+}
+
+// GetDstItem returns the item that the data with hash was stored as in dstID.
+// It returns false if the data wasn't stored in that destination.
+func (i *Index) GetDstItem(hash data.Hash, dstID DstID) (DstItem, bool) {
+	uref, ok := i.findRef(hash)
+	if !ok {
+		return DstItem{}, false
+	}
+	return uref.dstItem(dstID)
+}
+
+// FindBySrcDataURI returns the refs that have a SrcItem with dataURI, in any
+// source. This answers whether data has already been imported without reading
+// it. Since the data at a URI may change, more than one ref can be returned;
+// compare SrcItem.ModifiedAt to decide whether it must be read again.
+func (i *Index) FindBySrcDataURI(dataURI uri.URI) []*URef {
+	var refs []*URef
+	i.refLookup()
+	for _, uref := range i.lookup.srcURIs[dataURI.String()] {
+		for _, src := range uref.Srcs {
+			if src.DataURI.Equal(dataURI) {
+				refs = append(refs, uref)
+				break
+			}
+		}
+	}
+	return refs
+}
+
+// FindByDstDataURI returns the ref whose data is stored at dataURI in dstID.
+// It returns false if nothing is stored there.
+func (i *Index) FindByDstDataURI(dstID DstID, dataURI uri.URI) (*URef, bool) {
+	i.refLookup()
+	uref, ok := i.lookup.dstURIs[dstURIKey{dstID, dataURI.String()}]
+	if !ok {
+		return nil, false
+	}
+	for _, dst := range uref.Dsts {
+		if dst.DstID == dstID && dst.DataURI.Equal(dataURI) {
 			return uref, true
 		}
 	}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/uri"
 )
 
 func TestIndexSrc(t *testing.T) {
@@ -354,3 +355,91 @@ func TestURefDecomposeRefs(t *testing.T) {
 
 	}
 }
+
+func TestIndexFind(t *testing.T) {
+	var (
+		a = data.LiteralHash("a")
+		b = data.LiteralHash("b")
+	)
+	idx := &Index{
+		Refs: []*URef{
+			{
+				Hash: a,
+				Srcs: []SrcItem{{SrcID: SrcID("s1"), DataURI: uri.TrustedNew("file:///a.jpg")}},
+				Dsts: []DstItem{{DstID: DstID("d1"), DataURI: uri.TrustedNew("media/a.jpg")}},
+			},
+		},
+	}
+	idx.AddRef(Ref{
+		Hash: b,
+		Src:  SrcItem{SrcID: SrcID("s2"), DataURI: uri.TrustedNew("file:///a.jpg")},
+		Dst:  DstItem{DstID: DstID("d1"), DataURI: uri.TrustedNew("media/b.jpg")},
+	})
+
+	refs := idx.FindBySrcDataURI(uri.TrustedNew("file:///a.jpg"))
+	if got, want := len(refs), 2; got != want {
+		t.Fatalf("FindBySrcDataURI() got %d refs want %d", got, want)
+	}
+	if !refs[0].Hash.Equal(a) || !refs[1].Hash.Equal(b) {
+		t.Errorf("FindBySrcDataURI() got %s, %s want a, b", refs[0].Hash, refs[1].Hash)
+	}
+	if refs := idx.FindBySrcDataURI(uri.TrustedNew("file:///b.jpg")); len(refs) != 0 {
+		t.Errorf("FindBySrcDataURI(unknown) got %d refs want 0", len(refs))
+	}
+
+	ref, ok := idx.FindByDstDataURI(DstID("d1"), uri.TrustedNew("media/b.jpg"))
+	if !ok || !ref.Hash.Equal(b) {
+		t.Errorf("FindByDstDataURI(d1, b.jpg) got %v, %t want b", ref, ok)
+	}
+	if _, ok := idx.FindByDstDataURI(DstID("d2"), uri.TrustedNew("media/b.jpg")); ok {
+		t.Errorf("FindByDstDataURI(d2, b.jpg) must not be ok")
+	}
+
+	src, ok := idx.GetSrcItem(a, SrcID("s1"))
+	if !ok || !src.DataURI.Equal(uri.TrustedNew("file:///a.jpg")) {
+		t.Errorf("GetSrcItem(a, s1) got %s, %t", src, ok)
+	}
+	if _, ok := idx.GetSrcItem(a, SrcID("s2")); ok {
+		t.Errorf("GetSrcItem(a, s2) must not be ok")
+	}
+	dst, ok := idx.GetDstItem(b, DstID("d1"))
+	if !ok || !dst.DataURI.Equal(uri.TrustedNew("media/b.jpg")) {
+		t.Errorf("GetDstItem(b, d1) got %v, %t", dst, ok)
+	}
+	if _, ok := idx.GetDstItem(data.LiteralHash("c"), DstID("d1")); ok {
+		t.Errorf("GetDstItem(c, d1) must not be ok")
+	}
+}
+
+func TestIndexAddAlias(t *testing.T) {
+	var (
+		a = data.LiteralHash("a")
+		b = data.LiteralHash("b")
+		c = data.LiteralHash("c")
+	)
+	idx := New()
+	idx.AddRef(Ref{Hash: a, Src: SrcItem{SrcID: SrcID("s1")}})
+	idx.AddRef(Ref{Hash: c, Src: SrcItem{SrcID: SrcID("s1")}})
+
+	tests := []struct {
+		desc  string
+		hash  data.Hash
+		alias data.Hash
+		want  bool
+	}{
+		{"missing ref", b, a, false},
+		{"new alias", a, b, true},
+		{"same alias", a, b, false},
+		{"another ref's hash", a, c, false},
+		{"zero alias", a, data.Hash{}, false},
+	}
+	for _, tt := range tests {
+		if got := idx.AddAlias(tt.hash, tt.alias); got != tt.want {
+			t.Errorf("%q AddAlias() got %t want %t", tt.desc, got, tt.want)
+		}
+	}
+	uref, ok := idx.GetRef(b)
+	if !ok || !uref.Hash.Equal(a) {
+		t.Errorf("GetRef(b) got %v, %t want a", uref, ok)
+	}
+}
@@ -0,0 +1,269 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/recentralized/structure/data"
+)
+
+// logEvent is a line of the log after the header. Exactly one field is set.
+type logEvent struct {
+	Src   *Src      `json:"src,omitempty"`
+	Dst   *Dst      `json:"dst,omitempty"`
+	Ref   *logRef   `json:"ref,omitempty"`
+	Alias *logAlias `json:"alias,omitempty"`
+}
+
+type logRef struct {
+	Hash data.Hash `json:"hash"`
+	Src  SrcItem   `json:"src"`
+	Dst  DstItem   `json:"dst"`
+}
+
+type logAlias struct {
+	Hash  data.Hash `json:"hash"`
+	Alias data.Hash `json:"alias"`
+}
+
+// errEmptyLog is returned when a log has no header.
+var errEmptyLog = errors.New("index: log has no header")
+
+// LogWriter appends events to an index log.
+//
+// An index log is an append-only alternative to the index document. It's JSON
+// Lines: the first line is a header, which is an index document on a single
+// line, and each following line is an event that adds a Src, Dst, Ref or
+// alias. Appending an event is cheap, and a process that dies mid-write loses
+// at most the last event as long as the log is continued with AppendLog.
+// CompactLog rewrites a log as an index document.
+//
+//	{"version":"v1","srcs":[...],"dsts":[...]}
+//	{"ref":{"hash":"...","src":{...},"dst":{...}}}
+//	{"src":{...}}
+//	{"alias":{"hash":"...","alias":"..."}}
+type LogWriter struct {
+	w io.Writer
+}
+
+// NewLogWriter initializes a LogWriter that appends to w. A new log must
+// start with WriteHeader. To continue an existing log use AppendLog, which
+// first repairs a final line that was only partially written.
+func NewLogWriter(w io.Writer) *LogWriter {
+	return &LogWriter{w}
+}
+
+// AppendLog reads the index log in f, which must be open for reading and
+// writing, and returns its Index and a LogWriter that continues it. If the
+// final line was only partially written it's repaired so that new events
+// start on a line of their own: a complete event is kept by writing its
+// newline, and anything else is cut off.
+func AppendLog(f *os.File) (*Index, *LogWriter, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	idx, size, complete, err := loadLog(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := repairLog(f, size, complete); err != nil {
+		return nil, nil, err
+	}
+	return idx, NewLogWriter(f), nil
+}
+
+// WriteHeader starts a new log with the contents of idx, typically its Srcs
+// and Dsts. Any Refs are included too, so a compacted index can be continued
+// as a log.
+func (l *LogWriter) WriteHeader(idx *Index) error {
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return l.writeLine(b)
+}
+
+// AddSrc appends an event that adds a source.
+func (l *LogWriter) AddSrc(src Src) error {
+	return l.write(logEvent{Src: &src})
+}
+
+// AddDst appends an event that adds a destination.
+func (l *LogWriter) AddDst(dst Dst) error {
+	return l.write(logEvent{Dst: &dst})
+}
+
+// AddRef appends an event that adds a ref.
+func (l *LogWriter) AddRef(ref Ref) error {
+	return l.write(logEvent{Ref: &logRef{ref.Hash, ref.Src, ref.Dst}})
+}
+
+// AddAlias appends an event that adds an alias to the ref with hash.
+func (l *LogWriter) AddAlias(hash, alias data.Hash) error {
+	return l.write(logEvent{Alias: &logAlias{hash, alias}})
+}
+
+func (l *LogWriter) write(ev logEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return l.writeLine(b)
+}
+
+// writeLine writes b and its newline in a single write, so that a line is
+// rarely left partially written.
+func (l *LogWriter) writeLine(b []byte) error {
+	_, err := l.w.Write(append(b, '\n'))
+	return err
+}
+
+// LoadLog reads an index log, folding its events into an Index. A final line
+// that was only partially written is ignored.
+func LoadLog(r io.Reader) (*Index, error) {
+	idx, _, _, err := loadLog(r)
+	return idx, err
+}
+
+// loadLog reads an index log. Like replayLog, it returns the number of bytes
+// of complete lines, and whether a complete final line is missing its
+// newline.
+func loadLog(r io.Reader) (*Index, int64, bool, error) {
+	br := bufio.NewReader(r)
+	header, err := readLine(br)
+	if err == io.EOF {
+		return nil, 0, false, errEmptyLog
+	}
+	torn := err == io.ErrUnexpectedEOF
+	if err != nil && !torn {
+		return nil, 0, false, err
+	}
+	idx, err := ParseJSON(bytes.NewReader(header))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if torn {
+		return idx, 0, true, nil
+	}
+	size, complete, err := idx.replayLog(br, 2)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return idx, int64(len(header)) + 1 + size, complete, nil
+}
+
+// replayLog applies the events read from br, whose first line is number n of
+// the log. A final line that was only partially written is applied if it's a
+// complete event. It returns the number of bytes of complete lines read, and
+// whether the final line was a complete event missing its newline.
+func (i *Index) replayLog(br *bufio.Reader, n int) (int64, bool, error) {
+	var size int64
+	for ; ; n++ {
+		line, err := readLine(br)
+		if err == io.EOF {
+			return size, false, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			// A torn write at the end of the log. Keep the event
+			// only if it's complete.
+			var ev logEvent
+			if json.Unmarshal(line, &ev) != nil {
+				return size, false, nil
+			}
+			i.applyLogEvent(ev)
+			return size, true, nil
+		}
+		if err != nil {
+			return size, false, err
+		}
+		size += int64(len(line)) + 1
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var ev logEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			return size, false, fmt.Errorf("index: log line %d: %s", n, err)
+		}
+		i.applyLogEvent(ev)
+	}
+}
+
+// repairLog makes the log file f ready to append to. Its complete lines are
+// size bytes long. If complete, the rest of f is an event that's missing its
+// newline, which is written; otherwise the rest is cut off.
+func repairLog(f *os.File, size int64, complete bool) error {
+	if !complete {
+		if err := f.Truncate(size); err != nil {
+			return err
+		}
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if complete {
+		if _, err := f.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
+
+// readLine returns the next line without its newline. It returns
+// io.ErrUnexpectedEOF with the partial line if the input ends without one.
+func readLine(br *bufio.Reader) ([]byte, error) {
+	line, err := br.ReadBytes('\n')
+	if err == io.EOF {
+		if len(line) == 0 {
+			return nil, io.EOF
+		}
+		return line, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return line[:len(line)-1], nil
+}
+
+func (i *Index) applyLogEvent(ev logEvent) {
+	switch {
+	case ev.Src != nil:
+		i.AddSrc(*ev.Src)
+	case ev.Dst != nil:
+		i.AddDst(*ev.Dst)
+	case ev.Ref != nil:
+		i.AddRef(Ref{Hash: ev.Ref.Hash, Src: ev.Ref.Src, Dst: ev.Ref.Dst})
+	case ev.Alias != nil:
+		i.AddAlias(ev.Alias.Hash, ev.Alias.Alias)
+	}
+}
+
+// CompactLog reads an index log from r and writes it to w as an index
+// document, the same as written by json.Marshal of the Index.
+func CompactLog(w io.Writer, r io.Reader) error {
+	idx, err := LoadLog(r)
+	if err != nil {
+		return err
+	}
+	enc := NewEncoder(w)
+	for _, src := range idx.Srcs {
+		if err := enc.EncodeSrc(src); err != nil {
+			return err
+		}
+	}
+	for _, dst := range idx.Dsts {
+		if err := enc.EncodeDst(dst); err != nil {
+			return err
+		}
+	}
+	for _, uref := range idx.Refs {
+		if err := enc.EncodeRef(uref); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
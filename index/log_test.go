@@ -0,0 +1,223 @@
+package index
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/uri"
+)
+
+func TestLog(t *testing.T) {
+	var (
+		src  = NewSrc(uri.TrustedNew("file:///a/"))
+		dst  = NewDstAllAt(uri.TrustedNew("file:///b/"))
+		src2 = NewSrc(uri.TrustedNew("file:///c/"))
+	)
+	refs := []Ref{
+		{
+			Hash: data.LiteralHash("a"),
+			Src:  SrcItem{SrcID: src.SrcID, DataURI: uri.TrustedNew("a")},
+			Dst:  DstItem{DstID: dst.DstID, DataURI: uri.TrustedNew("a")},
+		},
+		{
+			Hash: data.LiteralHash("b"),
+			Src:  SrcItem{SrcID: src.SrcID, DataURI: uri.TrustedNew("b")},
+			Dst:  DstItem{DstID: dst.DstID, DataURI: uri.TrustedNew("b"), DataSize: 10},
+		},
+		{
+			Hash: data.LiteralHash("a"),
+			Src:  SrcItem{SrcID: src2.SrcID, DataURI: uri.TrustedNew("a")},
+			Dst:  DstItem{DstID: dst.DstID, DataURI: uri.TrustedNew("a")},
+		},
+	}
+
+	// Build the same index in memory and as a log.
+	want := New()
+	want.AddSrc(src)
+	want.AddDst(dst)
+
+	var buf bytes.Buffer
+	lw := NewLogWriter(&buf)
+	if err := lw.WriteHeader(want); err != nil {
+		t.Fatalf("WriteHeader() failed: %s", err)
+	}
+	want.AddSrc(src2)
+	if err := lw.AddSrc(src2); err != nil {
+		t.Fatalf("AddSrc() failed: %s", err)
+	}
+	for _, ref := range refs {
+		want.AddRef(ref)
+		if err := lw.AddRef(ref); err != nil {
+			t.Fatalf("AddRef() failed: %s", err)
+		}
+	}
+	want.AddAlias(data.LiteralHash("a"), data.LiteralHash("z"))
+	if err := lw.AddAlias(data.LiteralHash("a"), data.LiteralHash("z")); err != nil {
+		t.Fatalf("AddAlias() failed: %s", err)
+	}
+	if got, want := strings.Count(buf.String(), "\n"), 6; got != want {
+		t.Errorf("log has %d lines want %d", got, want)
+	}
+	wantJSON, _ := json.Marshal(want)
+
+	idx, err := LoadLog(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("LoadLog() failed: %s", err)
+	}
+	if gotJSON, _ := json.Marshal(idx); string(gotJSON) != string(wantJSON) {
+		t.Errorf("LoadLog()\ngot  %s\nwant %s", gotJSON, wantJSON)
+	}
+
+	var compact bytes.Buffer
+	if err := CompactLog(&compact, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("CompactLog() failed: %s", err)
+	}
+	if got := compact.String(); got != string(wantJSON) {
+		t.Errorf("CompactLog()\ngot  %s\nwant %s", got, wantJSON)
+	}
+
+	// A compacted index continues as a log.
+	compacted, err := ParseJSON(&compact)
+	if err != nil {
+		t.Fatalf("ParseJSON() failed: %s", err)
+	}
+	var next bytes.Buffer
+	if err := NewLogWriter(&next).WriteHeader(compacted); err != nil {
+		t.Fatalf("WriteHeader() failed: %s", err)
+	}
+	idx, err = LoadLog(&next)
+	if err != nil {
+		t.Fatalf("LoadLog() of compacted failed: %s", err)
+	}
+	if gotJSON, _ := json.Marshal(idx); string(gotJSON) != string(wantJSON) {
+		t.Errorf("LoadLog() of compacted\ngot  %s\nwant %s", gotJSON, wantJSON)
+	}
+}
+
+func TestLoadLogTorn(t *testing.T) {
+	header := `{"version":"v1"}` + "\n"
+	event := `{"ref":{"hash":"a","src":{"src_id":"s","data_uri":"a","meta_uri":""},"dst":{"dst_id":"d","data_uri":"a","meta_uri":""}}}`
+	tests := []struct {
+		desc    string
+		log     string
+		refs    int
+		wantErr bool
+	}{
+		{
+			desc: "complete",
+			log:  header + event + "\n",
+			refs: 1,
+		},
+		{
+			desc: "missing final newline",
+			log:  header + event,
+			refs: 1,
+		},
+		{
+			desc: "torn final line",
+			log:  header + event + "\n" + event[:20],
+			refs: 1,
+		},
+		{
+			desc:    "corrupt line",
+			log:     header + event[:20] + "\n" + event + "\n",
+			wantErr: true,
+		},
+		{
+			desc:    "empty",
+			log:     "",
+			wantErr: true,
+		},
+		{
+			desc:    "wrong version",
+			log:     `{"version":"v9"}` + "\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		idx, err := LoadLog(strings.NewReader(tt.log))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q LoadLog() expected an error", tt.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q LoadLog() failed: %s", tt.desc, err)
+		}
+		if got, want := len(idx.Refs), tt.refs; got != want {
+			t.Errorf("%q LoadLog() got %d refs want %d", tt.desc, got, want)
+		}
+	}
+}
+
+func TestAppendLog(t *testing.T) {
+	header := `{"version":"v1"}` + "\n"
+	event := `{"ref":{"hash":"a","src":{"src_id":"s","data_uri":"a","meta_uri":""},"dst":{"dst_id":"d","data_uri":"a","meta_uri":""}}}`
+	tests := []struct {
+		desc string
+		log  string
+		refs int
+	}{
+		{
+			desc: "complete",
+			log:  header + event + "\n",
+			refs: 2,
+		},
+		{
+			desc: "missing final newline",
+			log:  header + event,
+			refs: 2,
+		},
+		{
+			desc: "torn final line",
+			log:  header + event + "\n" + event[:20],
+			refs: 2,
+		},
+		{
+			desc: "torn header",
+			log:  header[:len(header)-1],
+			refs: 1,
+		},
+	}
+	for _, tt := range tests {
+		f, err := ioutil.TempFile("", "log")
+		if err != nil {
+			t.Fatalf("failed to create: %s", err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(tt.log); err != nil {
+			t.Fatalf("failed to write: %s", err)
+		}
+		_, w, err := AppendLog(f)
+		if err != nil {
+			t.Fatalf("%q AppendLog() failed: %s", tt.desc, err)
+		}
+		err = w.AddRef(Ref{
+			Hash: data.LiteralHash("b"),
+			Src:  SrcItem{SrcID: SrcID("s"), DataURI: uri.TrustedNew("b")},
+			Dst:  DstItem{DstID: DstID("d"), DataURI: uri.TrustedNew("b")},
+		})
+		if err != nil {
+			t.Fatalf("%q AddRef() failed: %s", tt.desc, err)
+		}
+		f.Close()
+
+		b, err := ioutil.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("failed to read: %s", err)
+		}
+		idx, err := LoadLog(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%q LoadLog() after append failed: %s", tt.desc, err)
+		}
+		if got, want := len(idx.Refs), tt.refs; got != want {
+			t.Errorf("%q LoadLog() after append got %d refs want %d", tt.desc, got, want)
+		}
+	}
+}
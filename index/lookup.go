@@ -0,0 +1,170 @@
+package index
+
+import (
+	"github.com/recentralized/structure/data"
+)
+
+// lookup holds maps of the Index's Srcs, Dsts and Refs, so that adds and
+// lookups don't scan them. It's built on first use. Each map is rebuilt
+// whenever its slice has been appended to or replaced outside of the Index's
+// methods, so an Index that's built directly or decoded from JSON is always
+// usable.
+type lookup struct {
+	srcs      map[SrcID]int
+	dsts      map[DstID]int
+	refs      map[string]*URef
+	srcURIs   map[string][]*URef
+	dstURIs   map[dstURIKey]*URef
+	srcsShape shape
+	dstsShape shape
+	refsShape shape
+}
+
+// shape is the backing array, length and capacity of a slice when its map
+// was last current. A slice that's replaced with another of the same length
+// still has a different array.
+type shape struct {
+	first interface{}
+	len   int
+	cap   int
+}
+
+func shapeOfSrcs(s []Src) shape {
+	if cap(s) == 0 {
+		return shape{}
+	}
+	return shape{&s[:1][0], len(s), cap(s)}
+}
+
+func shapeOfDsts(s []Dst) shape {
+	if cap(s) == 0 {
+		return shape{}
+	}
+	return shape{&s[:1][0], len(s), cap(s)}
+}
+
+func shapeOfRefs(s []*URef) shape {
+	if cap(s) == 0 {
+		return shape{}
+	}
+	return shape{&s[:1][0], len(s), cap(s)}
+}
+
+// dstURIKey identifies a DstItem's data in the lookup.
+type dstURIKey struct {
+	dstID   DstID
+	dataURI string
+}
+
+// Reindex rebuilds the Index's internal lookups. It's only required after
+// changing an element of Srcs, Dsts or Refs in place, or changing a URef's
+// hashes or items directly, rather than through the Index's methods.
+func (i *Index) Reindex() {
+	i.lookup = lookup{}
+}
+
+func (i *Index) srcLookup() map[SrcID]int {
+	if i.lookup.srcs == nil || i.lookup.srcsShape != shapeOfSrcs(i.Srcs) {
+		i.lookup.srcs = make(map[SrcID]int, len(i.Srcs))
+		for n, src := range i.Srcs {
+			if _, ok := i.lookup.srcs[src.SrcID]; !ok {
+				i.lookup.srcs[src.SrcID] = n
+			}
+		}
+		i.lookup.srcsShape = shapeOfSrcs(i.Srcs)
+	}
+	return i.lookup.srcs
+}
+
+func (i *Index) dstLookup() map[DstID]int {
+	if i.lookup.dsts == nil || i.lookup.dstsShape != shapeOfDsts(i.Dsts) {
+		i.lookup.dsts = make(map[DstID]int, len(i.Dsts))
+		for n, dst := range i.Dsts {
+			if _, ok := i.lookup.dsts[dst.DstID]; !ok {
+				i.lookup.dsts[dst.DstID] = n
+			}
+		}
+		i.lookup.dstsShape = shapeOfDsts(i.Dsts)
+	}
+	return i.lookup.dsts
+}
+
+func (i *Index) refLookup() map[string]*URef {
+	if i.lookup.refs == nil || i.lookup.refsShape != shapeOfRefs(i.Refs) {
+		i.lookup.refs = make(map[string]*URef, len(i.Refs))
+		i.lookup.srcURIs = make(map[string][]*URef, len(i.Refs))
+		i.lookup.dstURIs = make(map[dstURIKey]*URef, len(i.Refs))
+		for n := len(i.Refs) - 1; n >= 0; n-- {
+			i.indexRef(i.Refs[n])
+		}
+		i.lookup.refsShape = shapeOfRefs(i.Refs)
+	}
+	return i.lookup.refs
+}
+
+// indexRef adds the hashes and items of uref to the ref lookup, replacing
+// any ref that they pointed to.
+func (i *Index) indexRef(uref *URef) {
+	i.lookup.refs[uref.Hash.Key()] = uref
+	for _, alias := range uref.Aliases {
+		i.lookup.refs[alias.Key()] = uref
+	}
+	for _, src := range uref.Srcs {
+		i.indexSrcItem(uref, src)
+	}
+	for _, dst := range uref.Dsts {
+		i.indexDstItem(uref, dst)
+	}
+}
+
+// unindexRef removes the hashes and items of uref from the ref lookup.
+func (i *Index) unindexRef(uref *URef) {
+	for _, hash := range append([]data.Hash{uref.Hash}, uref.Aliases...) {
+		if i.lookup.refs[hash.Key()] == uref {
+			delete(i.lookup.refs, hash.Key())
+		}
+	}
+	for _, src := range uref.Srcs {
+		key := src.DataURI.String()
+		refs := i.lookup.srcURIs[key][:0]
+		for _, r := range i.lookup.srcURIs[key] {
+			if r != uref {
+				refs = append(refs, r)
+			}
+		}
+		if len(refs) == 0 {
+			delete(i.lookup.srcURIs, key)
+		} else {
+			i.lookup.srcURIs[key] = refs
+		}
+	}
+	for _, dst := range uref.Dsts {
+		key := dstURIKey{dst.DstID, dst.DataURI.String()}
+		if i.lookup.dstURIs[key] == uref {
+			delete(i.lookup.dstURIs, key)
+		}
+	}
+}
+
+func (i *Index) indexSrcItem(uref *URef, src SrcItem) {
+	key := src.DataURI.String()
+	for _, r := range i.lookup.srcURIs[key] {
+		if r == uref {
+			return
+		}
+	}
+	i.lookup.srcURIs[key] = append(i.lookup.srcURIs[key], uref)
+}
+
+func (i *Index) indexDstItem(uref *URef, dst DstItem) {
+	i.lookup.dstURIs[dstURIKey{dst.DstID, dst.DataURI.String()}] = uref
+}
+
+// findRef returns the ref with hash from the lookup.
+func (i *Index) findRef(hash data.Hash) (*URef, bool) {
+	uref, ok := i.refLookup()[hash.Key()]
+	if !ok || !uref.HasHash(hash) {
+		return nil, false
+	}
+	return uref, true
+}
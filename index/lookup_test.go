@@ -0,0 +1,106 @@
+package index
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/recentralized/structure/data"
+)
+
+func TestIndexLookupStaysCurrent(t *testing.T) {
+	idx := &Index{
+		Srcs: []Src{{SrcID: SrcID("a")}},
+		Dsts: []Dst{{DstID: DstID("a")}},
+		Refs: []*URef{{Hash: data.LiteralHash("a")}},
+	}
+	if _, ok := idx.GetSrc(SrcID("a")); !ok {
+		t.Errorf("GetSrc(a) must be ok")
+	}
+	if _, ok := idx.GetRef(data.LiteralHash("a")); !ok {
+		t.Errorf("GetRef(a) must be ok")
+	}
+
+	// Slices appended to directly are picked up.
+	idx.Srcs = append(idx.Srcs, Src{SrcID: SrcID("b")})
+	idx.Dsts = append(idx.Dsts, Dst{DstID: DstID("b")})
+	idx.Refs = append(idx.Refs, &URef{Hash: data.LiteralHash("b")})
+	if _, ok := idx.GetSrc(SrcID("b")); !ok {
+		t.Errorf("GetSrc(b) must be ok after append")
+	}
+	if _, ok := idx.GetDst(DstID("b")); !ok {
+		t.Errorf("GetDst(b) must be ok after append")
+	}
+	if _, ok := idx.GetRef(data.LiteralHash("b")); !ok {
+		t.Errorf("GetRef(b) must be ok after append")
+	}
+
+	// Slices replaced with another of the same length are picked up.
+	idx.Srcs = []Src{{SrcID: SrcID("a")}, {SrcID: SrcID("d")}}
+	idx.Dsts = []Dst{{DstID: DstID("a")}, {DstID: DstID("d")}}
+	idx.Refs = []*URef{{Hash: data.LiteralHash("a")}, {Hash: data.LiteralHash("d")}}
+	if _, ok := idx.GetSrc(SrcID("d")); !ok {
+		t.Errorf("GetSrc(d) must be ok after replacing Srcs")
+	}
+	if _, ok := idx.GetDst(DstID("d")); !ok {
+		t.Errorf("GetDst(d) must be ok after replacing Dsts")
+	}
+	if _, ok := idx.GetRef(data.LiteralHash("d")); !ok {
+		t.Errorf("GetRef(d) must be ok after replacing Refs")
+	}
+	if _, ok := idx.GetRef(data.LiteralHash("b")); ok {
+		t.Errorf("GetRef(b) must not be ok after replacing Refs")
+	}
+
+	// Changes in place need Reindex, but never return the wrong value.
+	idx.Srcs[1] = Src{SrcID: SrcID("c")}
+	if _, ok := idx.GetSrc(SrcID("d")); ok {
+		t.Errorf("GetSrc(d) must not be ok after it was replaced")
+	}
+	idx.Reindex()
+	if _, ok := idx.GetSrc(SrcID("c")); !ok {
+		t.Errorf("GetSrc(c) must be ok after Reindex")
+	}
+
+	// Aliases are found.
+	uref, _ := idx.GetRef(data.LiteralHash("a"))
+	uref.SetHash(data.LiteralHash("x"))
+	idx.Reindex()
+	for _, h := range []string{"a", "x"} {
+		got, ok := idx.GetRef(data.LiteralHash(h))
+		if !ok || got != uref {
+			t.Errorf("GetRef(%s) must return the ref with alias", h)
+		}
+	}
+}
+
+func TestIndexLookupLarge(t *testing.T) {
+	const n = 20000
+	idx := New()
+	for j := 0; j < n; j++ {
+		hash := data.LiteralHash(fmt.Sprintf("hash-%d", j))
+		idx.AddRef(Ref{
+			Hash: hash,
+			Src:  SrcItem{SrcID: SrcID("s")},
+			Dst:  DstItem{DstID: DstID("d")},
+		})
+	}
+	if got, want := len(idx.Refs), n; got != want {
+		t.Fatalf("len(Refs) got %d want %d", got, want)
+	}
+	for j := 0; j < n; j++ {
+		if _, ok := idx.GetRef(data.LiteralHash(fmt.Sprintf("hash-%d", j))); !ok {
+			t.Fatalf("GetRef(hash-%d) must be ok", j)
+		}
+	}
+}
+
+func BenchmarkIndexAddRef(b *testing.B) {
+	idx := New()
+	for j := 0; j < b.N; j++ {
+		idx.AddRef(Ref{
+			Hash: data.LiteralHash(fmt.Sprintf("hash-%d", j)),
+			Src:  SrcItem{SrcID: SrcID("s")},
+			Dst:  DstItem{DstID: DstID("d")},
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package index
+
+import (
+	"github.com/recentralized/structure/data"
+)
+
+// Conflict describes a DstItem whose immutable fields differ between two
+// indexes being merged. The merged index keeps the item from the first.
+type Conflict struct {
+
+	// Hash is the ref that the items belong to.
+	Hash data.Hash `json:"hash"`
+
+	// Fields are the names of the DstItem fields that differ.
+	Fields []string `json:"fields"`
+
+	// A and B are the items from the first and second index.
+	A DstItem `json:"a"`
+	B DstItem `json:"b"`
+}
+
+// Merge combines two indexes, such as those written by different machines for
+// the same destination, into a new one. Srcs, Dsts and Refs are combined by
+// their keys, and items with the same key by EqualKey. Neither a nor b is
+// modified.
+//
+// Mutable fields take the value with the latest timestamp: SrcItem.ModifiedAt,
+// DstItem.UpdatedAt with MetaSize, and DstItem.VerifiedAt. Immutable fields,
+// DstItem.DataSize and StoredAt, are never overwritten; differences are
+// returned as conflicts.
+func Merge(a, b *Index) (*Index, []Conflict) {
+	var (
+		idx       = New()
+		conflicts []Conflict
+	)
+	for _, x := range []*Index{a, b} {
+		for _, src := range x.Srcs {
+			idx.AddSrc(src)
+		}
+		for _, dst := range x.Dsts {
+			idx.AddDst(dst)
+		}
+	}
+	for _, x := range []*Index{a, b} {
+		for _, uref := range x.Refs {
+			existing, ok := idx.findAnyRef(uref)
+			if !ok {
+				existing = &URef{Hash: uref.Hash}
+				idx.Refs = append(idx.Refs, existing)
+				idx.lookup.refsShape = shapeOfRefs(idx.Refs)
+			}
+			conflicts = append(conflicts, existing.mergeLatest(uref)...)
+			idx.indexRef(existing)
+		}
+	}
+	return idx, conflicts
+}
+
+// findAnyRef returns the ref that has any of uref's hashes.
+func (i *Index) findAnyRef(uref *URef) (*URef, bool) {
+	if r, ok := i.findRef(uref.Hash); ok {
+		return r, true
+	}
+	for _, alias := range uref.Aliases {
+		if r, ok := i.findRef(alias); ok {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// mergeLatest adds the hashes, sources and destinations of another URef of the
+// same content, resolving mutable fields by the latest timestamp. It returns
+// conflicts in immutable fields, which are left unchanged.
+func (r *URef) mergeLatest(other *URef) []Conflict {
+	var conflicts []Conflict
+	r.AddAlias(other.Hash)
+	for _, a := range other.Aliases {
+		r.AddAlias(a)
+	}
+	for _, s := range other.Srcs {
+		n := r.srcIndex(s)
+		switch {
+		case n < 0:
+			r.Srcs = append(r.Srcs, s)
+		case s.ModifiedAt.After(r.Srcs[n].ModifiedAt):
+			r.Srcs[n].ModifiedAt = s.ModifiedAt
+		}
+	}
+	for _, d := range other.Dsts {
+		n := r.dstIndex(d)
+		if n < 0 {
+			r.Dsts = append(r.Dsts, d)
+			continue
+		}
+		existing := &r.Dsts[n]
+		var fields []string
+		if existing.DataSize != d.DataSize {
+			fields = append(fields, "DataSize")
+		}
+		if !existing.StoredAt.Equal(d.StoredAt) {
+			fields = append(fields, "StoredAt")
+		}
+		if len(fields) > 0 {
+			conflicts = append(conflicts, Conflict{
+				Hash:   r.Hash,
+				Fields: fields,
+				A:      *existing,
+				B:      d,
+			})
+		}
+		if d.UpdatedAt.After(existing.UpdatedAt) {
+			existing.UpdatedAt = d.UpdatedAt
+			existing.MetaSize = d.MetaSize
+		}
+		if d.VerifiedAt.After(existing.VerifiedAt) {
+			existing.VerifiedAt = d.VerifiedAt
+		}
+	}
+	return conflicts
+}
+
+// srcIndex returns the position of the SrcItem with the same key as src, or -1.
+func (r *URef) srcIndex(src SrcItem) int {
+	for n, s := range r.Srcs {
+		if s.EqualKey(src) {
+			return n
+		}
+	}
+	return -1
+}
+
+// dstIndex returns the position of the DstItem with the same key as dst, or -1.
+func (r *URef) dstIndex(dst DstItem) int {
+	for n, d := range r.Dsts {
+		if d.EqualKey(dst) {
+			return n
+		}
+	}
+	return -1
+}
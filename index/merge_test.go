@@ -0,0 +1,134 @@
+package index
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/uri"
+)
+
+func TestMerge(t *testing.T) {
+	var (
+		t1 = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+		t2 = time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+		t3 = time.Date(2018, 1, 3, 0, 0, 0, 0, time.UTC)
+	)
+	a := &Index{
+		Version: Version,
+		Srcs:    []Src{{SrcID: SrcID("s1")}},
+		Dsts:    []Dst{{DstID: DstID("d1")}},
+		Refs: []*URef{
+			{
+				Hash: data.LiteralHash("a"),
+				Srcs: []SrcItem{
+					{SrcID: SrcID("s1"), DataURI: uri.TrustedNew("a"), ModifiedAt: t1},
+				},
+				Dsts: []DstItem{
+					{DstID: DstID("d1"), DataURI: uri.TrustedNew("a"), DataSize: 10, MetaSize: 1, StoredAt: t1, UpdatedAt: t2},
+				},
+			},
+			{
+				Hash: data.LiteralHash("b"),
+				Dsts: []DstItem{
+					{DstID: DstID("d1"), DataURI: uri.TrustedNew("b"), DataSize: 20, StoredAt: t1},
+				},
+			},
+		},
+	}
+	b := &Index{
+		Version: Version,
+		Srcs:    []Src{{SrcID: SrcID("s1")}, {SrcID: SrcID("s2")}},
+		Dsts:    []Dst{{DstID: DstID("d1")}},
+		Refs: []*URef{
+			{
+				Hash: data.LiteralHash("a"),
+				Srcs: []SrcItem{
+					{SrcID: SrcID("s1"), DataURI: uri.TrustedNew("a"), ModifiedAt: t2},
+					{SrcID: SrcID("s2"), DataURI: uri.TrustedNew("a")},
+				},
+				Dsts: []DstItem{
+					{DstID: DstID("d1"), DataURI: uri.TrustedNew("a"), DataSize: 10, MetaSize: 2, StoredAt: t1, UpdatedAt: t3},
+				},
+			},
+			{
+				Hash:    data.LiteralHash("b2"),
+				Aliases: []data.Hash{data.LiteralHash("b")},
+				Dsts: []DstItem{
+					{DstID: DstID("d1"), DataURI: uri.TrustedNew("b"), DataSize: 21, StoredAt: t2},
+				},
+			},
+			{
+				Hash: data.LiteralHash("c"),
+				Srcs: []SrcItem{
+					{SrcID: SrcID("s2"), DataURI: uri.TrustedNew("c")},
+				},
+			},
+		},
+	}
+	aJSON, _ := a.Refs[0].MarshalJSON()
+	bJSON, _ := b.Refs[0].MarshalJSON()
+
+	got, conflicts := Merge(a, b)
+
+	want := &Index{
+		Version: Version,
+		Srcs:    []Src{{SrcID: SrcID("s1")}, {SrcID: SrcID("s2")}},
+		Dsts:    []Dst{{DstID: DstID("d1")}},
+		Refs: []*URef{
+			{
+				Hash: data.LiteralHash("a"),
+				Srcs: []SrcItem{
+					{SrcID: SrcID("s1"), DataURI: uri.TrustedNew("a"), ModifiedAt: t2},
+					{SrcID: SrcID("s2"), DataURI: uri.TrustedNew("a")},
+				},
+				Dsts: []DstItem{
+					{DstID: DstID("d1"), DataURI: uri.TrustedNew("a"), DataSize: 10, MetaSize: 2, StoredAt: t1, UpdatedAt: t3},
+				},
+			},
+			{
+				Hash:    data.LiteralHash("b"),
+				Aliases: []data.Hash{data.LiteralHash("b2")},
+				Dsts: []DstItem{
+					{DstID: DstID("d1"), DataURI: uri.TrustedNew("b"), DataSize: 20, StoredAt: t1},
+				},
+			},
+			{
+				Hash: data.LiteralHash("c"),
+				Srcs: []SrcItem{
+					{SrcID: SrcID("s2"), DataURI: uri.TrustedNew("c")},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got.Srcs, want.Srcs) || !reflect.DeepEqual(got.Dsts, want.Dsts) {
+		t.Errorf("Merge() srcs and dsts\ngot  %#v %#v\nwant %#v %#v", got.Srcs, got.Dsts, want.Srcs, want.Dsts)
+	}
+	if !reflect.DeepEqual(got.Refs, want.Refs) {
+		t.Errorf("Merge() refs\ngot  %#v\nwant %#v", got.Refs, want.Refs)
+	}
+	if _, ok := got.GetRef(data.LiteralHash("b2")); !ok {
+		t.Errorf("GetRef(b2) must be ok after merge")
+	}
+
+	wantConflicts := []Conflict{
+		{
+			Hash:   data.LiteralHash("b"),
+			Fields: []string{"DataSize", "StoredAt"},
+			A:      DstItem{DstID: DstID("d1"), DataURI: uri.TrustedNew("b"), DataSize: 20, StoredAt: t1},
+			B:      DstItem{DstID: DstID("d1"), DataURI: uri.TrustedNew("b"), DataSize: 21, StoredAt: t2},
+		},
+	}
+	if !reflect.DeepEqual(conflicts, wantConflicts) {
+		t.Errorf("Merge() conflicts\ngot  %#v\nwant %#v", conflicts, wantConflicts)
+	}
+
+	// Inputs are unchanged.
+	if j, _ := a.Refs[0].MarshalJSON(); string(j) != string(aJSON) {
+		t.Errorf("Merge() modified a\ngot  %s\nwant %s", j, aJSON)
+	}
+	if j, _ := b.Refs[0].MarshalJSON(); string(j) != string(bJSON) {
+		t.Errorf("Merge() modified b\ngot  %s\nwant %s", j, bJSON)
+	}
+}
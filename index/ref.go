@@ -46,6 +46,12 @@ func (r Ref) String() string {
 // algorithms to add and retrieve data.
 type URef struct {
 	Hash data.Hash
+
+	// Aliases are other hashes of the same content, typically calculated
+	// in a different format. They are kept when the primary Hash is
+	// replaced so that refs can be found by any of them.
+	Aliases []data.Hash
+
 	Srcs []SrcItem
 	Dsts []DstItem
 }
@@ -62,6 +68,51 @@ func (r URef) String() string {
 	return fmt.Sprintf("<URef %s srcs:%d dsts:%d>", r.Hash, len(r.Srcs), len(r.Dsts))
 }
 
+// HasHash returns true if hash is the ref's Hash or one of its Aliases.
+func (r URef) HasHash(hash data.Hash) bool {
+	if r.Hash.Equal(hash) {
+		return true
+	}
+	for _, a := range r.Aliases {
+		if a.Equal(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddAlias adds another hash of the same content. It's idempotent, returning
+// true if the URef was modified.
+func (r *URef) AddAlias(hash data.Hash) bool {
+	if hash.IsZero() || r.HasHash(hash) {
+		return false
+	}
+	r.Aliases = append(r.Aliases, hash)
+	return true
+}
+
+// SetHash replaces the ref's Hash, keeping the previous Hash as an alias. It
+// returns true if the URef was modified.
+func (r *URef) SetHash(hash data.Hash) bool {
+	if r.Hash.Equal(hash) {
+		return false
+	}
+	old := r.Hash
+	aliases := make([]data.Hash, 0, len(r.Aliases)+1)
+	for _, a := range r.Aliases {
+		if !a.Equal(hash) {
+			aliases = append(aliases, a)
+		}
+	}
+	r.Hash = hash
+	r.Aliases = aliases
+	r.AddAlias(old)
+	if len(r.Aliases) == 0 {
+		r.Aliases = nil
+	}
+	return true
+}
+
 // DecomposeRefs returns an array of individual Refs.
 func (r URef) DecomposeRefs() []Ref {
 	refs := make([]Ref, 0, len(r.Srcs)*len(r.Dsts))
@@ -96,11 +147,15 @@ func (r *URef) AddSrc(src SrcItem) bool {
 }
 
 // AddDst adds a DstItem to the ref. If a matching DstItem exists, it's mutable
-// attributes will be updated. The method returns true if any changes to the
-// URef or existing DstItem occurred.
+// attributes will be updated, except that its VerifiedAt is kept if dst's is
+// zero. The method returns true if any changes to the URef or existing DstItem
+// occurred.
 func (r *URef) AddDst(dst DstItem) bool {
 	for i, d := range r.Dsts {
 		if d.EqualKey(dst) {
+			if dst.VerifiedAt.IsZero() {
+				dst.VerifiedAt = d.VerifiedAt
+			}
 			if d.Equal(dst) {
 				return false
 			}
@@ -112,3 +167,33 @@ func (r *URef) AddDst(dst DstItem) bool {
 	r.Dsts = append(r.Dsts, dst)
 	return true
 }
+
+// RemoveSrc removes the SrcItem with the same key as src from the ref. The
+// method returns true if the URef was modified.
+func (r *URef) RemoveSrc(src SrcItem) bool {
+	for i, s := range r.Srcs {
+		if s.EqualKey(src) {
+			r.Srcs = append(r.Srcs[:i], r.Srcs[i+1:]...)
+			if len(r.Srcs) == 0 {
+				r.Srcs = nil
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveDst removes the DstItem with the same key as dst from the ref. The
+// method returns true if the URef was modified.
+func (r *URef) RemoveDst(dst DstItem) bool {
+	for i, d := range r.Dsts {
+		if d.EqualKey(dst) {
+			r.Dsts = append(r.Dsts[:i], r.Dsts[i+1:]...)
+			if len(r.Dsts) == 0 {
+				r.Dsts = nil
+			}
+			return true
+		}
+	}
+	return false
+}
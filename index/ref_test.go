@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/recentralized/structure/data"
 	"github.com/recentralized/structure/uri"
 )
 
@@ -262,6 +263,34 @@ func TestURefAddDst(t *testing.T) {
 			},
 			update: true,
 		},
+		{
+			desc: "keep verified time",
+			start: &URef{
+				Dsts: []DstItem{
+					{
+						DstID:      DstID("a"),
+						DataURI:    uri.TrustedNew("a"),
+						VerifiedAt: time.Date(1, 2, 3, 4, 5, 6, 7, time.UTC),
+					},
+				},
+			},
+			add: DstItem{
+				DstID:    DstID("a"),
+				DataURI:  uri.TrustedNew("a"),
+				MetaSize: 10,
+			},
+			want: &URef{
+				Dsts: []DstItem{
+					{
+						DstID:      DstID("a"),
+						DataURI:    uri.TrustedNew("a"),
+						MetaSize:   10,
+						VerifiedAt: time.Date(1, 2, 3, 4, 5, 6, 7, time.UTC),
+					},
+				},
+			},
+			update: true,
+		},
 	}
 	for _, tt := range tests {
 		update := tt.start.AddDst(tt.add)
@@ -273,3 +302,82 @@ func TestURefAddDst(t *testing.T) {
 		}
 	}
 }
+
+func TestURefHashes(t *testing.T) {
+	var (
+		a = data.LiteralHash("a")
+		b = data.LiteralHash("b")
+		c = data.LiteralHash("c")
+	)
+	r := &URef{Hash: a}
+	if !r.HasHash(a) {
+		t.Errorf("HasHash(a) must be true")
+	}
+	if r.HasHash(b) {
+		t.Errorf("HasHash(b) must be false")
+	}
+	if r.AddAlias(a) {
+		t.Errorf("AddAlias(a) must not modify")
+	}
+	if r.AddAlias(data.Hash{}) {
+		t.Errorf("AddAlias(zero) must not modify")
+	}
+	if !r.AddAlias(b) {
+		t.Errorf("AddAlias(b) must modify")
+	}
+	if r.AddAlias(b) {
+		t.Errorf("AddAlias(b) must be idempotent")
+	}
+	if !r.HasHash(b) {
+		t.Errorf("HasHash(b) must be true after AddAlias")
+	}
+	if r.SetHash(a) {
+		t.Errorf("SetHash(a) must not modify")
+	}
+	if !r.SetHash(c) {
+		t.Errorf("SetHash(c) must modify")
+	}
+	want := &URef{Hash: c, Aliases: []data.Hash{b, a}}
+	if got, want := r, want; !reflect.DeepEqual(got, want) {
+		t.Errorf("SetHash(c)\ngot  %#v\nwant %#v", got, want)
+	}
+	if !r.SetHash(b) {
+		t.Errorf("SetHash(b) must modify")
+	}
+	want = &URef{Hash: b, Aliases: []data.Hash{a, c}}
+	if got, want := r, want; !reflect.DeepEqual(got, want) {
+		t.Errorf("SetHash(b)\ngot  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestURefRemoveItems(t *testing.T) {
+	r := &URef{
+		Srcs: []SrcItem{
+			{SrcID: SrcID("a"), DataURI: uri.TrustedNew("a")},
+			{SrcID: SrcID("a"), DataURI: uri.TrustedNew("b")},
+		},
+		Dsts: []DstItem{
+			{DstID: DstID("a"), DataURI: uri.TrustedNew("a"), DataSize: 100},
+		},
+	}
+	if r.RemoveSrc(SrcItem{SrcID: SrcID("b"), DataURI: uri.TrustedNew("a")}) {
+		t.Errorf("RemoveSrc() of a different key must not modify")
+	}
+	if !r.RemoveSrc(SrcItem{SrcID: SrcID("a"), DataURI: uri.TrustedNew("a")}) {
+		t.Errorf("RemoveSrc() must modify")
+	}
+	if r.RemoveSrc(SrcItem{SrcID: SrcID("a"), DataURI: uri.TrustedNew("a")}) {
+		t.Errorf("RemoveSrc() must be idempotent")
+	}
+	if !r.RemoveDst(DstItem{DstID: DstID("a"), DataURI: uri.TrustedNew("a")}) {
+		t.Errorf("RemoveDst() matches by key and must modify")
+	}
+	want := &URef{
+		Srcs: []SrcItem{
+			{SrcID: SrcID("a"), DataURI: uri.TrustedNew("b")},
+		},
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("Remove\ngot  %#v\nwant %#v", r, want)
+	}
+}
@@ -0,0 +1,100 @@
+package index
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/recentralized/structure/data"
+)
+
+// OpenFunc opens the stored data of a DstItem for reading. The reader returns
+// the bytes as stored; they're decoded with the item's DataType by the
+// caller.
+type OpenFunc func(Dst, DstItem) (io.ReadCloser, error)
+
+// HashFunc calculates the hash of data, such as data.NewHash or a
+// dst.Layout's NewHash.
+type HashFunc func(io.Reader) (data.Hash, error)
+
+// Rehash calculates a new hash for every ref stored in the destination
+// dstID, reading each one's data with open. The new hash becomes the ref's
+// Hash and the previous one is kept as an alias, so lookups by either
+// continue to work. If the new hash belongs to another ref, the two are
+// merged. It returns the number of refs that were changed.
+//
+// This is how an index is moved to a new hash format, for example:
+//
+//	n, err := idx.Rehash(dstID, open, dst.NewFilesystemLayout(dst.HashFormat(cid.CidV1)).NewHash)
+func (i *Index) Rehash(dstID DstID, open OpenFunc, newHash HashFunc) (int, error) {
+	dst, ok := i.GetDst(dstID)
+	if !ok {
+		return 0, fmt.Errorf("index: unknown dst %q", dstID)
+	}
+	var changed int
+	for n := 0; n < len(i.Refs); n++ {
+		uref := i.Refs[n]
+		item, ok := uref.dstItem(dstID)
+		if !ok {
+			continue
+		}
+		hash, err := rehashItem(dst, item, open, newHash)
+		if err != nil {
+			return changed, fmt.Errorf("index: rehash %s: %s", uref.Hash, err)
+		}
+		if uref.Hash.Equal(hash) {
+			continue
+		}
+		if other, ok := i.GetRef(hash); ok && other != uref {
+			// The content is already known by its new hash. Fold
+			// this ref into that one.
+			i.unindexRef(uref)
+			other.merge(uref)
+			other.SetHash(hash)
+			i.Refs = append(i.Refs[:n], i.Refs[n+1:]...)
+			i.indexRef(other)
+			i.lookup.refsShape = shapeOfRefs(i.Refs)
+			n--
+		} else {
+			uref.SetHash(hash)
+			i.indexRef(uref)
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+func rehashItem(dst Dst, item DstItem, open OpenFunc, newHash HashFunc) (data.Hash, error) {
+	r, err := open(dst, item)
+	if err != nil {
+		return data.Hash{}, err
+	}
+	defer r.Close()
+	dr := item.DataType.Decode(r)
+	defer dr.Close()
+	return newHash(dr)
+}
+
+// dstItem returns the first DstItem stored in dstID.
+func (r *URef) dstItem(dstID DstID) (DstItem, bool) {
+	for _, d := range r.Dsts {
+		if d.DstID == dstID {
+			return d, true
+		}
+	}
+	return DstItem{}, false
+}
+
+// merge adds the hashes, sources and destinations of another URef of the same
+// content.
+func (r *URef) merge(other *URef) {
+	r.AddAlias(other.Hash)
+	for _, a := range other.Aliases {
+		r.AddAlias(a)
+	}
+	for _, s := range other.Srcs {
+		r.AddSrc(s)
+	}
+	for _, d := range other.Dsts {
+		r.AddDst(d)
+	}
+}
@@ -0,0 +1,171 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/recentralized/structure/cid"
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/uri"
+)
+
+func TestIndexRehash(t *testing.T) {
+	contents := map[string]string{
+		"a.jpg": "aaa",
+		"b.jpg": "bbb",
+	}
+	open := func(dst Dst, item DstItem) (io.ReadCloser, error) {
+		c, ok := contents[item.DataURI.String()]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return ioutil.NopCloser(bytes.NewBufferString(c)), nil
+	}
+	newHash := func(r io.Reader) (data.Hash, error) {
+		return data.NewHashFormat(r, cid.CidV1)
+	}
+	hash := func(s string, f cid.Format) data.Hash {
+		h, err := data.NewHashFormat(bytes.NewBufferString(s), f)
+		if err != nil {
+			t.Fatalf("failed to hash: %s", err)
+		}
+		return h
+	}
+	var (
+		sha1A = hash("aaa", cid.SHA1)
+		sha1B = hash("bbb", cid.SHA1)
+		cidA  = hash("aaa", cid.CidV1)
+		cidB  = hash("bbb", cid.CidV1)
+	)
+	dstItem := func(dstID, path string) DstItem {
+		return DstItem{DstID: DstID(dstID), DataURI: uri.TrustedNew(path)}
+	}
+
+	tests := []struct {
+		desc        string
+		idx         *Index
+		dstID       DstID
+		wantChanged int
+		wantRefs    []*URef
+		wantErr     bool
+	}{
+		{
+			desc: "rehash keeps old hash as alias",
+			idx: &Index{
+				Dsts: []Dst{{DstID: DstID("d1")}},
+				Refs: []*URef{
+					{Hash: sha1A, Dsts: []DstItem{dstItem("d1", "a.jpg")}},
+				},
+			},
+			dstID:       DstID("d1"),
+			wantChanged: 1,
+			wantRefs: []*URef{
+				{Hash: cidA, Aliases: []data.Hash{sha1A}, Dsts: []DstItem{dstItem("d1", "a.jpg")}},
+			},
+		},
+		{
+			desc: "already rehashed",
+			idx: &Index{
+				Dsts: []Dst{{DstID: DstID("d1")}},
+				Refs: []*URef{
+					{Hash: cidA, Aliases: []data.Hash{sha1A}, Dsts: []DstItem{dstItem("d1", "a.jpg")}},
+				},
+			},
+			dstID:       DstID("d1"),
+			wantChanged: 0,
+			wantRefs: []*URef{
+				{Hash: cidA, Aliases: []data.Hash{sha1A}, Dsts: []DstItem{dstItem("d1", "a.jpg")}},
+			},
+		},
+		{
+			desc: "other dsts are ignored",
+			idx: &Index{
+				Dsts: []Dst{{DstID: DstID("d1")}, {DstID: DstID("d2")}},
+				Refs: []*URef{
+					{Hash: sha1A, Dsts: []DstItem{dstItem("d1", "a.jpg")}},
+					{Hash: sha1B, Dsts: []DstItem{dstItem("d2", "b.jpg")}},
+				},
+			},
+			dstID:       DstID("d2"),
+			wantChanged: 1,
+			wantRefs: []*URef{
+				{Hash: sha1A, Dsts: []DstItem{dstItem("d1", "a.jpg")}},
+				{Hash: cidB, Aliases: []data.Hash{sha1B}, Dsts: []DstItem{dstItem("d2", "b.jpg")}},
+			},
+		},
+		{
+			desc: "merges with ref of new hash",
+			idx: &Index{
+				Dsts: []Dst{{DstID: DstID("d1")}, {DstID: DstID("d2")}},
+				Refs: []*URef{
+					{
+						Hash: sha1A,
+						Srcs: []SrcItem{{SrcID: SrcID("s1")}},
+						Dsts: []DstItem{dstItem("d1", "a.jpg")},
+					},
+					{
+						Hash: cidA,
+						Srcs: []SrcItem{{SrcID: SrcID("s2")}},
+						Dsts: []DstItem{dstItem("d2", "x.jpg")},
+					},
+				},
+			},
+			dstID:       DstID("d1"),
+			wantChanged: 1,
+			wantRefs: []*URef{
+				{
+					Hash:    cidA,
+					Aliases: []data.Hash{sha1A},
+					Srcs:    []SrcItem{{SrcID: SrcID("s2")}, {SrcID: SrcID("s1")}},
+					Dsts:    []DstItem{dstItem("d2", "x.jpg"), dstItem("d1", "a.jpg")},
+				},
+			},
+		},
+		{
+			desc:    "unknown dst",
+			idx:     &Index{},
+			dstID:   DstID("d1"),
+			wantErr: true,
+		},
+		{
+			desc: "open fails",
+			idx: &Index{
+				Dsts: []Dst{{DstID: DstID("d1")}},
+				Refs: []*URef{
+					{Hash: sha1A, Dsts: []DstItem{dstItem("d1", "missing.jpg")}},
+				},
+			},
+			dstID:   DstID("d1"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		changed, err := tt.idx.Rehash(tt.dstID, open, newHash)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q expected error", tt.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q failed: %s", tt.desc, err)
+		}
+		if got, want := changed, tt.wantChanged; got != want {
+			t.Errorf("%q changed got %d want %d", tt.desc, got, want)
+		}
+		if got, want := tt.idx.Refs, tt.wantRefs; !reflect.DeepEqual(got, want) {
+			t.Errorf("%q Refs\ngot  %s\nwant %s", tt.desc, got, want)
+		}
+		for _, r := range tt.wantRefs {
+			for _, h := range append([]data.Hash{r.Hash}, r.Aliases...) {
+				if _, ok := tt.idx.GetRef(h); !ok {
+					t.Errorf("%q GetRef(%s) must be ok", tt.desc, h)
+				}
+			}
+		}
+	}
+}
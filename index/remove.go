@@ -0,0 +1,111 @@
+package index
+
+import (
+	"github.com/recentralized/structure/data"
+)
+
+// OrphanPolicy decides what happens to a ref that's left with no Dsts, meaning
+// that its data is no longer stored anywhere.
+type OrphanPolicy int
+
+const (
+	// KeepOrphans keeps the ref with its Srcs. It records that the data
+	// was found, and may be stored again from a source.
+	KeepOrphans OrphanPolicy = iota
+
+	// RemoveOrphans removes the ref from the index.
+	RemoveOrphans
+)
+
+// RemoveRef removes the ref with hash, which may be its Hash or any of its
+// Aliases. It returns true if the index was modified.
+func (i *Index) RemoveRef(hash data.Hash) bool {
+	uref, ok := i.findRef(hash)
+	if !ok {
+		return false
+	}
+	for n, u := range i.Refs {
+		if u == uref {
+			i.unindexRef(uref)
+			i.Refs = append(i.Refs[:n], i.Refs[n+1:]...)
+			i.lookup.refsShape = shapeOfRefs(i.Refs)
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveSrc removes a source from the index, along with every SrcItem found in
+// it. Refs that are left with no Srcs and no Dsts are removed. It returns true
+// if the index was modified.
+func (i *Index) RemoveSrc(srcID SrcID) bool {
+	var changed bool
+	for n, src := range i.Srcs {
+		if src.SrcID == srcID {
+			i.Srcs = append(i.Srcs[:n], i.Srcs[n+1:]...)
+			changed = true
+			break
+		}
+	}
+	refs := i.Refs[:0]
+	for _, uref := range i.Refs {
+		var removed bool
+		for n := 0; n < len(uref.Srcs); n++ {
+			if uref.Srcs[n].SrcID == srcID {
+				uref.RemoveSrc(uref.Srcs[n])
+				removed = true
+				n--
+			}
+		}
+		if removed {
+			changed = true
+			if len(uref.Srcs) == 0 && len(uref.Dsts) == 0 {
+				continue
+			}
+		}
+		refs = append(refs, uref)
+	}
+	i.Refs = refs
+	if changed {
+		i.Reindex()
+	}
+	return changed
+}
+
+// RemoveDst removes a destination from the index, along with every DstItem
+// stored in it. Refs that are left with no Dsts are kept or removed according
+// to orphans, and those with no Srcs either are always removed. It returns
+// true if the index was modified.
+func (i *Index) RemoveDst(dstID DstID, orphans OrphanPolicy) bool {
+	var changed bool
+	for n, dst := range i.Dsts {
+		if dst.DstID == dstID {
+			i.Dsts = append(i.Dsts[:n], i.Dsts[n+1:]...)
+			changed = true
+			break
+		}
+	}
+	refs := i.Refs[:0]
+	for _, uref := range i.Refs {
+		var removed bool
+		for n := 0; n < len(uref.Dsts); n++ {
+			if uref.Dsts[n].DstID == dstID {
+				uref.RemoveDst(uref.Dsts[n])
+				removed = true
+				n--
+			}
+		}
+		if removed {
+			changed = true
+			if len(uref.Dsts) == 0 && (orphans == RemoveOrphans || len(uref.Srcs) == 0) {
+				continue
+			}
+		}
+		refs = append(refs, uref)
+	}
+	i.Refs = refs
+	if changed {
+		i.Reindex()
+	}
+	return changed
+}
@@ -0,0 +1,125 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/uri"
+)
+
+func newRemoveTestIndex() *Index {
+	idx := New()
+	idx.AddSrc(Src{SrcID: SrcID("s1")})
+	idx.AddSrc(Src{SrcID: SrcID("s2")})
+	idx.AddDst(Dst{DstID: DstID("d1")})
+	idx.AddDst(Dst{DstID: DstID("d2")})
+	for _, ref := range []Ref{
+		{
+			Hash: data.LiteralHash("a"),
+			Src:  SrcItem{SrcID: SrcID("s1"), DataURI: uri.TrustedNew("a")},
+			Dst:  DstItem{DstID: DstID("d1"), DataURI: uri.TrustedNew("a")},
+		},
+		{
+			Hash: data.LiteralHash("a"),
+			Src:  SrcItem{SrcID: SrcID("s2"), DataURI: uri.TrustedNew("a")},
+			Dst:  DstItem{DstID: DstID("d2"), DataURI: uri.TrustedNew("a")},
+		},
+		{
+			Hash: data.LiteralHash("b"),
+			Src:  SrcItem{SrcID: SrcID("s1"), DataURI: uri.TrustedNew("b")},
+			Dst:  DstItem{DstID: DstID("d1"), DataURI: uri.TrustedNew("b")},
+		},
+	} {
+		idx.AddRef(ref)
+	}
+	return idx
+}
+
+func TestIndexRemoveRef(t *testing.T) {
+	idx := newRemoveTestIndex()
+	if !idx.RemoveRef(data.LiteralHash("a")) {
+		t.Errorf("RemoveRef(a) must modify")
+	}
+	if idx.RemoveRef(data.LiteralHash("a")) {
+		t.Errorf("RemoveRef(a) must be idempotent")
+	}
+	if _, ok := idx.GetRef(data.LiteralHash("a")); ok {
+		t.Errorf("GetRef(a) must not be ok after removal")
+	}
+	if refs := idx.FindBySrcDataURI(uri.TrustedNew("a")); len(refs) != 0 {
+		t.Errorf("FindBySrcDataURI(a) got %d refs after removal", len(refs))
+	}
+	if got, want := len(idx.Refs), 1; got != want {
+		t.Errorf("len(Refs) got %d want %d", got, want)
+	}
+}
+
+func TestIndexRemoveSrc(t *testing.T) {
+	idx := newRemoveTestIndex()
+	if !idx.RemoveSrc(SrcID("s1")) {
+		t.Errorf("RemoveSrc(s1) must modify")
+	}
+	if idx.RemoveSrc(SrcID("s1")) {
+		t.Errorf("RemoveSrc(s1) must be idempotent")
+	}
+	if _, ok := idx.GetSrc(SrcID("s1")); ok {
+		t.Errorf("GetSrc(s1) must not be ok after removal")
+	}
+	if _, ok := idx.GetSrcItem(data.LiteralHash("a"), SrcID("s1")); ok {
+		t.Errorf("GetSrcItem(a, s1) must not be ok after removal")
+	}
+	if _, ok := idx.GetSrcItem(data.LiteralHash("a"), SrcID("s2")); !ok {
+		t.Errorf("GetSrcItem(a, s2) must be ok")
+	}
+	// Stored data stays in the index without a source.
+	if _, ok := idx.GetDstItem(data.LiteralHash("b"), DstID("d1")); !ok {
+		t.Errorf("GetDstItem(b, d1) must be ok")
+	}
+	if got, want := len(idx.Refs), 2; got != want {
+		t.Errorf("len(Refs) got %d want %d", got, want)
+	}
+}
+
+func TestIndexRemoveDst(t *testing.T) {
+	tests := []struct {
+		desc    string
+		orphans OrphanPolicy
+		refs    int
+	}{
+		{
+			desc:    "keep orphans",
+			orphans: KeepOrphans,
+			refs:    2,
+		},
+		{
+			desc:    "remove orphans",
+			orphans: RemoveOrphans,
+			refs:    1,
+		},
+	}
+	for _, tt := range tests {
+		idx := newRemoveTestIndex()
+		if !idx.RemoveDst(DstID("d1"), tt.orphans) {
+			t.Errorf("%q RemoveDst(d1) must modify", tt.desc)
+		}
+		if idx.RemoveDst(DstID("d1"), tt.orphans) {
+			t.Errorf("%q RemoveDst(d1) must be idempotent", tt.desc)
+		}
+		if _, ok := idx.GetDst(DstID("d1")); ok {
+			t.Errorf("%q GetDst(d1) must not be ok after removal", tt.desc)
+		}
+		if _, ok := idx.FindByDstDataURI(DstID("d1"), uri.TrustedNew("a")); ok {
+			t.Errorf("%q FindByDstDataURI(d1, a) must not be ok after removal", tt.desc)
+		}
+		if _, ok := idx.GetDstItem(data.LiteralHash("a"), DstID("d2")); !ok {
+			t.Errorf("%q GetDstItem(a, d2) must be ok", tt.desc)
+		}
+		if got, want := len(idx.Refs), tt.refs; got != want {
+			t.Errorf("%q len(Refs) got %d want %d", tt.desc, got, want)
+		}
+		_, ok := idx.GetRef(data.LiteralHash("b"))
+		if got, want := ok, tt.orphans == KeepOrphans; got != want {
+			t.Errorf("%q GetRef(b) got %t want %t", tt.desc, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package index
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/recentralized/structure/data"
+)
+
+// ScrubOptions control Scrub.
+type ScrubOptions struct {
+
+	// Fraction is the portion of the destination's items to verify in a
+	// run, greater than 0 and at most 1. At least one item is verified.
+	// Items found corrupt are reported in addition.
+	Fraction float64
+}
+
+// Corrupt is an item whose stored data doesn't match its ref's hash.
+type Corrupt struct {
+	Hash data.Hash `json:"hash"`
+	Item DstItem   `json:"item"`
+
+	// Detail describes how the data is wrong.
+	Detail string `json:"detail"`
+
+	// Copies are the ref's items in other destinations, which the data
+	// can be restored from.
+	Copies []DstItem `json:"copies,omitempty"`
+}
+
+// ScrubReport is the result of Scrub. It's suitable for JSON encoding.
+type ScrubReport struct {
+
+	// Items is the number of items stored in the destination.
+	Items int `json:"items"`
+
+	// Verified is the number of items whose data was read and is correct.
+	Verified int `json:"verified"`
+
+	// Corrupt are the items whose data is wrong or missing.
+	Corrupt []Corrupt `json:"corrupt"`
+}
+
+// Scrub re-verifies a fraction of the data stored in the destination dstID,
+// reading each item with open, decoding it and hashing it. Run it periodically
+// to find data that has silently changed on the storage media.
+//
+// Items are verified in the order they were last verified, those never
+// verified first, so successive runs cover the whole destination and a run
+// that's interrupted picks up where it left off. The VerifiedAt of each item
+// that's correct is set to now; corrupt items keep theirs so they're checked
+// again first until they're restored. Corrupt items don't count toward the
+// fraction, so the rest of the destination is still verified while they wait
+// to be restored.
+//
+// If the destination can't be read Scrub stops, returning the report so far
+// along with the error. A missing file is reported as corrupt.
+func (i *Index) Scrub(dstID DstID, open OpenFunc, opts ScrubOptions) (*ScrubReport, error) {
+	dst, ok := i.GetDst(dstID)
+	if !ok {
+		return nil, fmt.Errorf("index: unknown dst %q", dstID)
+	}
+	if !(opts.Fraction > 0 && opts.Fraction <= 1) {
+		return nil, fmt.Errorf("index: scrub fraction %v must be greater than 0 and at most 1", opts.Fraction)
+	}
+	type scrubItem struct {
+		uref *URef
+		n    int
+	}
+	var items []scrubItem
+	for _, uref := range i.Refs {
+		for n, d := range uref.Dsts {
+			if d.DstID == dstID {
+				items = append(items, scrubItem{uref, n})
+			}
+		}
+	}
+	sort.SliceStable(items, func(a, b int) bool {
+		x := items[a].uref.Dsts[items[a].n]
+		y := items[b].uref.Dsts[items[b].n]
+		if !x.VerifiedAt.Equal(y.VerifiedAt) {
+			return x.VerifiedAt.Before(y.VerifiedAt)
+		}
+		return items[a].uref.Hash.Key() < items[b].uref.Hash.Key()
+	})
+
+	report := &ScrubReport{Items: len(items)}
+	count := int(math.Ceil(opts.Fraction * float64(len(items))))
+	for _, it := range items {
+		if report.Verified == count {
+			break
+		}
+		item := it.uref.Dsts[it.n]
+		detail, err := scrubItemData(dst, item, it.uref.Hash, open)
+		if err != nil {
+			return report, fmt.Errorf("index: scrub %s: %s", it.uref.Hash, err)
+		}
+		if detail == "" {
+			it.uref.Dsts[it.n].VerifiedAt = time.Now().UTC()
+			report.Verified++
+			continue
+		}
+		c := Corrupt{Hash: it.uref.Hash, Item: item, Detail: detail}
+		for _, d := range it.uref.Dsts {
+			if d.DstID != dstID {
+				c.Copies = append(c.Copies, d)
+			}
+		}
+		report.Corrupt = append(report.Corrupt, c)
+	}
+	return report, nil
+}
+
+// scrubItemData reads and decodes the data of item and verifies that it has
+// hash. It returns a description of what's wrong with the data, or an error if
+// it couldn't be opened.
+func scrubItemData(dst Dst, item DstItem, hash data.Hash, open OpenFunc) (string, error) {
+	r, err := open(dst, item)
+	if os.IsNotExist(err) {
+		return "missing", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	dr := item.DataType.Decode(r)
+	defer dr.Close()
+	_, err = io.Copy(ioutil.Discard, data.NewVerifyingReader(dr, hash))
+	if err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,205 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/uri"
+)
+
+func TestIndexScrub(t *testing.T) {
+	contents := map[string]string{
+		"d1/a": "aaa",
+		"d1/b": "bbb",
+		"d1/c": "ccc",
+		"d1/d": "ddd",
+		"d2/a": "aaa",
+	}
+	var openErr error
+	open := func(dst Dst, item DstItem) (io.ReadCloser, error) {
+		if openErr != nil {
+			return nil, openErr
+		}
+		c, ok := contents[string(item.DstID)+"/"+item.DataURI.String()]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return ioutil.NopCloser(bytes.NewBufferString(c)), nil
+	}
+	idx := New()
+	idx.AddDst(Dst{DstID: DstID("d1")})
+	idx.AddDst(Dst{DstID: DstID("d2")})
+	hashes := make(map[string]data.Hash)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		h, err := data.NewHash(bytes.NewBufferString(contents["d1/"+name]))
+		if err != nil {
+			t.Fatalf("failed to hash: %s", err)
+		}
+		hashes[name] = h
+		idx.AddRef(Ref{Hash: h, Dst: DstItem{DstID: DstID("d1"), DataURI: uri.TrustedNew(name)}})
+	}
+	idx.AddRef(Ref{Hash: hashes["a"], Dst: DstItem{DstID: DstID("d2"), DataURI: uri.TrustedNew("a")}})
+
+	verified := func() map[string]bool {
+		v := make(map[string]bool)
+		for name, h := range hashes {
+			if item, _ := idx.GetDstItem(h, DstID("d1")); !item.VerifiedAt.IsZero() {
+				v[name] = true
+			}
+		}
+		return v
+	}
+
+	// Two runs of half cover everything.
+	opts := ScrubOptions{Fraction: 0.5}
+	report, err := idx.Scrub(DstID("d1"), open, opts)
+	if err != nil {
+		t.Fatalf("Scrub() failed: %s", err)
+	}
+	if report.Items != 4 || report.Verified != 2 || len(report.Corrupt) != 0 {
+		t.Errorf("Scrub() #1 got %+v want 2 of 4 verified", report)
+	}
+	first := verified()
+	if got, want := len(first), 2; got != want {
+		t.Errorf("Scrub() #1 verified %d items want %d", got, want)
+	}
+	report, err = idx.Scrub(DstID("d1"), open, opts)
+	if err != nil {
+		t.Fatalf("Scrub() failed: %s", err)
+	}
+	if report.Verified != 2 {
+		t.Errorf("Scrub() #2 got %+v want 2 verified", report)
+	}
+	if got, want := len(verified()), 4; got != want {
+		t.Errorf("Scrub() #2 verified %d items want %d", got, want)
+	}
+	if item, _ := idx.GetDstItem(hashes["a"], DstID("d2")); !item.VerifiedAt.IsZero() {
+		t.Errorf("Scrub() must only verify items in dstID")
+	}
+
+	// Corrupt and missing data is reported, along with other copies.
+	contents["d1/a"] = "aab"
+	delete(contents, "d1/b")
+	before, _ := idx.GetDstItem(hashes["a"], DstID("d1"))
+	report, err = idx.Scrub(DstID("d1"), open, ScrubOptions{Fraction: 1})
+	if err != nil {
+		t.Fatalf("Scrub() failed: %s", err)
+	}
+	if report.Verified != 2 || len(report.Corrupt) != 2 {
+		t.Fatalf("Scrub() #3 got %+v want 2 verified and 2 corrupt", report)
+	}
+	for _, c := range report.Corrupt {
+		switch {
+		case c.Hash.Equal(hashes["a"]):
+			if c.Detail != data.ErrHashMismatch.Error() {
+				t.Errorf("corrupt a got detail %q", c.Detail)
+			}
+			if len(c.Copies) != 1 || c.Copies[0].DstID != DstID("d2") {
+				t.Errorf("corrupt a got copies %v want d2", c.Copies)
+			}
+		case c.Hash.Equal(hashes["b"]):
+			if c.Detail != "missing" || len(c.Copies) != 0 {
+				t.Errorf("corrupt b got %+v want missing with no copies", c)
+			}
+		default:
+			t.Errorf("unexpected corrupt item %+v", c)
+		}
+	}
+	if after, _ := idx.GetDstItem(hashes["a"], DstID("d1")); !after.VerifiedAt.Equal(before.VerifiedAt) {
+		t.Errorf("corrupt item must keep its VerifiedAt")
+	}
+
+	openErr = errors.New("offline")
+	if _, err := idx.Scrub(DstID("d1"), open, opts); err == nil {
+		t.Errorf("Scrub() must fail if data can't be opened")
+	}
+	if _, err := idx.Scrub(DstID("d3"), open, opts); err == nil {
+		t.Errorf("Scrub() of unknown dst must fail")
+	}
+	for _, f := range []float64{0, -1, 1.5} {
+		if _, err := idx.Scrub(DstID("d1"), open, ScrubOptions{Fraction: f}); err == nil {
+			t.Errorf("Scrub() with fraction %v must fail", f)
+		}
+	}
+}
+
+func TestIndexScrubManyCorrupt(t *testing.T) {
+	contents := map[string]string{"a": "aaa", "b": "bbb", "c": "ccc", "d": "ddd"}
+	open := func(dst Dst, item DstItem) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewBufferString(contents[item.DataURI.String()])), nil
+	}
+	idx := New()
+	idx.AddDst(Dst{DstID: DstID("d1")})
+	for _, name := range []string{"a", "b", "c", "d"} {
+		h, err := data.NewHash(bytes.NewBufferString(contents[name]))
+		if err != nil {
+			t.Fatalf("failed to hash: %s", err)
+		}
+		idx.AddRef(Ref{Hash: h, Dst: DstItem{DstID: DstID("d1"), DataURI: uri.TrustedNew(name)}})
+	}
+	if _, err := idx.Scrub(DstID("d1"), open, ScrubOptions{Fraction: 1}); err != nil {
+		t.Fatalf("Scrub() failed: %s", err)
+	}
+
+	// More items are corrupt than are verified in a run. The healthy item
+	// is still verified each time. Once it's the most recently verified,
+	// every corrupt item is reported before it.
+	contents["a"], contents["b"], contents["c"] = "xxx", "xxx", "xxx"
+	for n := 1; n <= 2; n++ {
+		report, err := idx.Scrub(DstID("d1"), open, ScrubOptions{Fraction: 0.25})
+		if err != nil {
+			t.Fatalf("Scrub() failed: %s", err)
+		}
+		if report.Verified != 1 {
+			t.Errorf("Scrub() #%d got %d verified want 1", n, report.Verified)
+		}
+		if n == 2 && len(report.Corrupt) != 3 {
+			t.Errorf("Scrub() #%d got %d corrupt want 3", n, len(report.Corrupt))
+		}
+	}
+}
+
+func TestIndexScrubEncoded(t *testing.T) {
+	content := []byte("aaa")
+	hash, err := data.NewHash(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("failed to hash: %s", err)
+	}
+	typ := data.Stored{Type: data.JPG, Encoding: data.GZip}
+	var buf bytes.Buffer
+	w := typ.Encode(&buf)
+	w.Write(content)
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to encode: %s", err)
+	}
+	stored := buf.Bytes()
+	open := func(dst Dst, item DstItem) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(stored)), nil
+	}
+	idx := New()
+	idx.AddDst(Dst{DstID: DstID("d1")})
+	idx.AddRef(Ref{Hash: hash, Dst: DstItem{DstID: DstID("d1"), DataURI: uri.TrustedNew("a"), DataType: typ}})
+
+	report, err := idx.Scrub(DstID("d1"), open, ScrubOptions{Fraction: 1})
+	if err != nil {
+		t.Fatalf("Scrub() failed: %s", err)
+	}
+	if report.Verified != 1 || len(report.Corrupt) != 0 {
+		t.Errorf("Scrub() got %+v want 1 verified", report)
+	}
+
+	// Data that can't be decoded is corrupt.
+	stored = []byte("aaa")
+	report, err = idx.Scrub(DstID("d1"), open, ScrubOptions{Fraction: 1})
+	if err != nil {
+		t.Fatalf("Scrub() failed: %s", err)
+	}
+	if report.Verified != 0 || len(report.Corrupt) != 1 {
+		t.Errorf("Scrub() got %+v want 1 corrupt", report)
+	}
+}
@@ -0,0 +1,143 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migrations are the changes to the schema, in order. A migration's version is
+// its position in the list plus one. Once released a migration must never be
+// changed; add another one instead.
+var migrations = [][]string{
+	// 1: srcs, dsts and the items found in and stored to them.
+	{
+		`CREATE TABLE srcs (
+			id      INTEGER PRIMARY KEY,
+			src_id  TEXT NOT NULL UNIQUE,
+			src_uri BLOB NOT NULL
+		)`,
+		`CREATE TABLE dsts (
+			id        INTEGER PRIMARY KEY,
+			dst_id    TEXT NOT NULL UNIQUE,
+			index_uri BLOB NOT NULL,
+			data_uri  BLOB NOT NULL,
+			meta_uri  BLOB NOT NULL
+		)`,
+		`CREATE TABLE refs (
+			id       INTEGER PRIMARY KEY,
+			hash     BLOB NOT NULL,
+			hash_key TEXT NOT NULL UNIQUE
+		)`,
+		`CREATE TABLE src_items (
+			id          INTEGER PRIMARY KEY,
+			ref_id      INTEGER NOT NULL REFERENCES refs (id),
+			src_id      TEXT NOT NULL,
+			data_uri    BLOB NOT NULL,
+			meta_uri    BLOB NOT NULL,
+			modified_at TEXT,
+			UNIQUE (ref_id, src_id, data_uri, meta_uri)
+		)`,
+		`CREATE TABLE dst_items (
+			id         INTEGER PRIMARY KEY,
+			ref_id     INTEGER NOT NULL REFERENCES refs (id),
+			dst_id     TEXT NOT NULL,
+			data_uri   BLOB NOT NULL,
+			meta_uri   BLOB NOT NULL,
+			data_type  BLOB NOT NULL,
+			data_size  INTEGER NOT NULL,
+			meta_size  INTEGER NOT NULL,
+			stored_at  TEXT,
+			updated_at TEXT,
+			UNIQUE (ref_id, dst_id, data_uri, meta_uri)
+		)`,
+		`CREATE INDEX src_items_src_id ON src_items (src_id)`,
+		`CREATE INDEX dst_items_dst_id ON dst_items (dst_id)`,
+	},
+	// 2: find items by where they are, for FindBySrcDataURI and
+	// FindByDstDataURI.
+	{
+		`CREATE INDEX src_items_data_uri ON src_items (data_uri)`,
+		`CREATE INDEX dst_items_data_uri ON dst_items (dst_id, data_uri)`,
+	},
+	// 3: DstItem.VerifiedAt.
+	{
+		`ALTER TABLE dst_items ADD COLUMN verified_at TEXT`,
+	},
+	// 4: URef.Aliases, the other hashes that a ref is found by.
+	{
+		`CREATE TABLE ref_hashes (
+			id       INTEGER PRIMARY KEY,
+			ref_id   INTEGER NOT NULL REFERENCES refs (id),
+			hash     BLOB NOT NULL,
+			hash_key TEXT NOT NULL UNIQUE
+		)`,
+		`CREATE INDEX ref_hashes_ref_id ON ref_hashes (ref_id)`,
+	},
+}
+
+// SchemaVersion is the version of the schema that Migrate brings a database
+// to.
+var SchemaVersion = len(migrations)
+
+// Migrate brings the schema of db up to SchemaVersion, applying each
+// migration that's missing in its own transaction. The versions applied are
+// recorded in the schema_migrations table. It returns an error if db has a
+// newer schema than this package knows.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	return migrateTo(ctx, db, SchemaVersion)
+}
+
+// migrateTo brings the schema of db up to version.
+func migrateTo(ctx context.Context, db *sql.DB, target int) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("sqlstore: create schema_migrations: %s", err)
+	}
+	version, err := Version(ctx, db)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("sqlstore: schema version %d is newer than %d", version, SchemaVersion)
+	}
+	for n := version; n < target; n++ {
+		if err := migrate(ctx, db, n+1, migrations[n]); err != nil {
+			return fmt.Errorf("sqlstore: migration %d: %s", n+1, err)
+		}
+	}
+	return nil
+}
+
+// Version returns the schema version of db. It's 0 if no migrations have been
+// applied.
+func Version(ctx context.Context, db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	err := db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("sqlstore: schema version: %s", err)
+	}
+	return int(version.Int64), nil
+}
+
+func migrate(ctx context.Context, db *sql.DB, version int, stmts []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+		version, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
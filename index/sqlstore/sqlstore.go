@@ -0,0 +1,700 @@
+// Package sqlstore implements index.Store with database/sql, so that an index
+// can be queried and updated without loading all of it into memory.
+//
+// The schema is written for SQLite. It's created and upgraded by Migrate, which
+// New calls. Any SQLite driver can be used, such as the pure Go
+// modernc.org/sqlite:
+//
+//	db, err := sql.Open("sqlite", "index.db")
+//	...
+//	store, err := sqlstore.New(ctx, db)
+//
+// Srcs, dsts and refs are stored in their own tables, and each SrcItem and
+// DstItem is a row in src_items or dst_items that refers to its ref. A ref's
+// Aliases are rows in ref_hashes.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/index"
+	"github.com/recentralized/structure/uri"
+)
+
+// pageSize is the number of rows read at a time while iterating. Rows are
+// read before calling back, so no query is open while the callback runs.
+const pageSize = 100
+
+// Store is an index.Store kept in a SQL database. It's safe for concurrent use
+// to the extent that the database is.
+type Store struct {
+	db *sql.DB
+}
+
+var _ index.Store = (*Store)(nil)
+
+// New initializes a Store in db, migrating its schema to SchemaVersion.
+func New(ctx context.Context, db *sql.DB) (*Store, error) {
+	if err := Migrate(ctx, db); err != nil {
+		return nil, err
+	}
+	return &Store{db}, nil
+}
+
+// querier is the part of sql.DB and sql.Tx used to read.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// AddSrc adds a source. It's idempotent, returning true if the store was
+// modified.
+func (s *Store) AddSrc(ctx context.Context, src index.Src) (bool, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO srcs (src_id, src_uri) VALUES (?, ?) ON CONFLICT (src_id) DO NOTHING`,
+		string(src.SrcID), src.SrcURI)
+	if err != nil {
+		return false, fmt.Errorf("sqlstore: add src: %s", err)
+	}
+	changed, err := affected(res)
+	if err != nil {
+		return false, fmt.Errorf("sqlstore: add src: %s", err)
+	}
+	return changed, nil
+}
+
+// GetSrc returns the source with srcID. It returns false if no source was
+// found.
+func (s *Store) GetSrc(ctx context.Context, srcID index.SrcID) (index.Src, bool, error) {
+	src := index.Src{SrcID: srcID}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT src_uri FROM srcs WHERE src_id = ?`,
+		string(srcID)).Scan(&src.SrcURI)
+	if err == sql.ErrNoRows {
+		return index.Src{}, false, nil
+	}
+	if err != nil {
+		return index.Src{}, false, fmt.Errorf("sqlstore: get src: %s", err)
+	}
+	return src, true, nil
+}
+
+// RemoveSrc removes a source along with every SrcItem found in it. Refs that
+// are left with no SrcItems or DstItems are removed too.
+func (s *Store) RemoveSrc(ctx context.Context, srcID index.SrcID) (bool, error) {
+	var changed bool
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM srcs WHERE src_id = ?`, string(srcID))
+		if err != nil {
+			return err
+		}
+		changed, err = affected(res)
+		if err != nil {
+			return err
+		}
+		refIDs, err := queryIDs(ctx, tx,
+			`SELECT DISTINCT ref_id FROM src_items WHERE src_id = ? ORDER BY ref_id`,
+			string(srcID))
+		if err != nil {
+			return err
+		}
+		if len(refIDs) == 0 {
+			return nil
+		}
+		changed = true
+		if _, err := tx.ExecContext(ctx, `DELETE FROM src_items WHERE src_id = ?`, string(srcID)); err != nil {
+			return err
+		}
+		for _, id := range refIDs {
+			if err := removeOrphan(ctx, tx, id, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("sqlstore: remove src: %s", err)
+	}
+	return changed, nil
+}
+
+// Srcs calls fn for each source, in the order they were added.
+func (s *Store) Srcs(ctx context.Context, fn func(index.Src) error) error {
+	var srcs []index.Src
+	return s.pages(ctx,
+		`SELECT id, src_id, src_uri FROM srcs WHERE id > ? ORDER BY id LIMIT ?`,
+		func(rows *sql.Rows) (int64, error) {
+			var id int64
+			var src index.Src
+			err := rows.Scan(&id, &src.SrcID, &src.SrcURI)
+			srcs = append(srcs, src)
+			return id, err
+		},
+		func() error {
+			defer func() { srcs = srcs[:0] }()
+			for _, src := range srcs {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if err := fn(src); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+}
+
+// AddDst adds a destination. It's idempotent, returning true if the store was
+// modified.
+func (s *Store) AddDst(ctx context.Context, dst index.Dst) (bool, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO dsts (dst_id, index_uri, data_uri, meta_uri) VALUES (?, ?, ?, ?) ON CONFLICT (dst_id) DO NOTHING`,
+		string(dst.DstID), dst.IndexURI, dst.DataURI, dst.MetaURI)
+	if err != nil {
+		return false, fmt.Errorf("sqlstore: add dst: %s", err)
+	}
+	changed, err := affected(res)
+	if err != nil {
+		return false, fmt.Errorf("sqlstore: add dst: %s", err)
+	}
+	return changed, nil
+}
+
+// GetDst returns the destination with dstID. It returns false if no
+// destination was found.
+func (s *Store) GetDst(ctx context.Context, dstID index.DstID) (index.Dst, bool, error) {
+	dst := index.Dst{DstID: dstID}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT index_uri, data_uri, meta_uri FROM dsts WHERE dst_id = ?`,
+		string(dstID)).Scan(&dst.IndexURI, &dst.DataURI, &dst.MetaURI)
+	if err == sql.ErrNoRows {
+		return index.Dst{}, false, nil
+	}
+	if err != nil {
+		return index.Dst{}, false, fmt.Errorf("sqlstore: get dst: %s", err)
+	}
+	return dst, true, nil
+}
+
+// RemoveDst removes a destination along with every DstItem stored in it. Refs
+// that are left with no DstItems are kept or removed according to orphans,
+// and those with no SrcItems either are always removed.
+func (s *Store) RemoveDst(ctx context.Context, dstID index.DstID, orphans index.OrphanPolicy) (bool, error) {
+	var changed bool
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM dsts WHERE dst_id = ?`, string(dstID))
+		if err != nil {
+			return err
+		}
+		changed, err = affected(res)
+		if err != nil {
+			return err
+		}
+		refIDs, err := queryIDs(ctx, tx,
+			`SELECT DISTINCT ref_id FROM dst_items WHERE dst_id = ? ORDER BY ref_id`,
+			string(dstID))
+		if err != nil {
+			return err
+		}
+		if len(refIDs) == 0 {
+			return nil
+		}
+		changed = true
+		if _, err := tx.ExecContext(ctx, `DELETE FROM dst_items WHERE dst_id = ?`, string(dstID)); err != nil {
+			return err
+		}
+		for _, id := range refIDs {
+			if err := removeOrphan(ctx, tx, id, orphans == index.KeepOrphans); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("sqlstore: remove dst: %s", err)
+	}
+	return changed, nil
+}
+
+// Dsts calls fn for each destination, in the order they were added.
+func (s *Store) Dsts(ctx context.Context, fn func(index.Dst) error) error {
+	var dsts []index.Dst
+	return s.pages(ctx,
+		`SELECT id, dst_id, index_uri, data_uri, meta_uri FROM dsts WHERE id > ? ORDER BY id LIMIT ?`,
+		func(rows *sql.Rows) (int64, error) {
+			var id int64
+			var dst index.Dst
+			err := rows.Scan(&id, &dst.DstID, &dst.IndexURI, &dst.DataURI, &dst.MetaURI)
+			dsts = append(dsts, dst)
+			return id, err
+		},
+		func() error {
+			defer func() { dsts = dsts[:0] }()
+			for _, dst := range dsts {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if err := fn(dst); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+}
+
+// AddRef adds a ref, like Index.AddRef. If the ref's SrcItem or DstItem
+// exists, its mutable attributes are updated. It returns true if the store
+// was modified.
+func (s *Store) AddRef(ctx context.Context, ref index.Ref) (bool, error) {
+	var changed bool
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		refID, err := addRef(ctx, tx, ref.Hash)
+		if err != nil {
+			return err
+		}
+		addSrc, err := addSrcItem(ctx, tx, refID, ref.Src)
+		if err != nil {
+			return err
+		}
+		addDst, err := addDstItem(ctx, tx, refID, ref.Dst)
+		if err != nil {
+			return err
+		}
+		changed = addSrc || addDst
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("sqlstore: add ref: %s", err)
+	}
+	return changed, nil
+}
+
+// AddAlias adds alias as another hash of the ref with hash. It returns false
+// if no ref has hash, or if alias is already a hash of a ref.
+func (s *Store) AddAlias(ctx context.Context, hash, alias data.Hash) (bool, error) {
+	if alias.IsZero() {
+		return false, nil
+	}
+	var changed bool
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		id, ok, err := findRef(ctx, tx, hash)
+		if err != nil || !ok {
+			return err
+		}
+		_, ok, err = findRef(ctx, tx, alias)
+		if err != nil || ok {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO ref_hashes (ref_id, hash, hash_key) VALUES (?, ?, ?)`,
+			id, alias, alias.Key())
+		changed = err == nil
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("sqlstore: add alias: %s", err)
+	}
+	return changed, nil
+}
+
+// GetRef returns the URef with hash, which may be its Hash or any of its
+// Aliases, with its items in the order they were added. It returns false if
+// no ref was found.
+func (s *Store) GetRef(ctx context.Context, hash data.Hash) (*index.URef, bool, error) {
+	id, ok, err := findRef(ctx, s.db, hash)
+	if err != nil {
+		return nil, false, fmt.Errorf("sqlstore: get ref: %s", err)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	uref, err := getRef(ctx, s.db, id)
+	if err != nil {
+		return nil, false, fmt.Errorf("sqlstore: get ref: %s", err)
+	}
+	return uref, true, nil
+}
+
+// RemoveRef removes the ref with hash. It returns true if the store was
+// modified.
+func (s *Store) RemoveRef(ctx context.Context, hash data.Hash) (bool, error) {
+	var changed bool
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		id, ok, err := findRef(ctx, tx, hash)
+		if err != nil || !ok {
+			return err
+		}
+		changed = true
+		return removeRef(ctx, tx, id)
+	})
+	if err != nil {
+		return false, fmt.Errorf("sqlstore: remove ref: %s", err)
+	}
+	return changed, nil
+}
+
+// Refs calls fn for each ref, in the order they were added. Each ref is read
+// just before fn is called with it.
+func (s *Store) Refs(ctx context.Context, fn func(*index.URef) error) error {
+	var ids []int64
+	var urefs []*index.URef
+	return s.pages(ctx,
+		`SELECT id, hash FROM refs WHERE id > ? ORDER BY id LIMIT ?`,
+		func(rows *sql.Rows) (int64, error) {
+			var id int64
+			uref := &index.URef{}
+			err := rows.Scan(&id, &uref.Hash)
+			ids = append(ids, id)
+			urefs = append(urefs, uref)
+			return id, err
+		},
+		func() error {
+			defer func() { ids, urefs = ids[:0], urefs[:0] }()
+			for n, uref := range urefs {
+				if err := loadItems(ctx, s.db, ids[n], uref); err != nil {
+					return err
+				}
+				if err := fn(uref); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+}
+
+// FindBySrcDataURI returns the refs that have a SrcItem with dataURI, in any
+// source, like Index.FindBySrcDataURI.
+func (s *Store) FindBySrcDataURI(ctx context.Context, dataURI uri.URI) ([]*index.URef, error) {
+	ids, err := queryIDs(ctx, s.db,
+		`SELECT DISTINCT ref_id FROM src_items WHERE data_uri = ? ORDER BY ref_id`,
+		dataURI)
+	if err != nil {
+		return nil, fmt.Errorf("sqlstore: find by src data uri: %s", err)
+	}
+	var urefs []*index.URef
+	for _, id := range ids {
+		uref, err := getRef(ctx, s.db, id)
+		if err != nil {
+			return nil, fmt.Errorf("sqlstore: find by src data uri: %s", err)
+		}
+		urefs = append(urefs, uref)
+	}
+	return urefs, nil
+}
+
+// FindByDstDataURI returns the ref whose data is stored at dataURI in dstID,
+// like Index.FindByDstDataURI. It returns false if nothing is stored there.
+func (s *Store) FindByDstDataURI(ctx context.Context, dstID index.DstID, dataURI uri.URI) (*index.URef, bool, error) {
+	ids, err := queryIDs(ctx, s.db,
+		`SELECT ref_id FROM dst_items WHERE dst_id = ? AND data_uri = ? ORDER BY id LIMIT 1`,
+		string(dstID), dataURI)
+	if err != nil {
+		return nil, false, fmt.Errorf("sqlstore: find by dst data uri: %s", err)
+	}
+	if len(ids) == 0 {
+		return nil, false, nil
+	}
+	uref, err := getRef(ctx, s.db, ids[0])
+	if err != nil {
+		return nil, false, fmt.Errorf("sqlstore: find by dst data uri: %s", err)
+	}
+	return uref, true, nil
+}
+
+// update runs fn in a transaction, committing it if fn succeeds.
+func (s *Store) update(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// pages runs query for successive pages of rows, ordered by id. The query
+// takes the id to start after and the page size. scan is called for each row
+// and returns its id; flush is called after each page has been read.
+func (s *Store) pages(ctx context.Context, query string, scan func(*sql.Rows) (int64, error), flush func() error) error {
+	var after int64
+	for {
+		rows, err := s.db.QueryContext(ctx, query, after, pageSize)
+		if err != nil {
+			return err
+		}
+		var n int
+		for rows.Next() {
+			id, err := scan(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			after = id
+			n++
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if n < pageSize {
+			return nil
+		}
+	}
+}
+
+// findRef returns the id of the ref with hash, which may be its hash or any
+// of its aliases. It returns false if no ref was found.
+func findRef(ctx context.Context, q querier, hash data.Hash) (int64, bool, error) {
+	var id int64
+	err := q.QueryRowContext(ctx,
+		`SELECT id FROM refs WHERE hash_key = ? UNION ALL SELECT ref_id FROM ref_hashes WHERE hash_key = ? LIMIT 1`,
+		hash.Key(), hash.Key()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return id, err == nil, err
+}
+
+// addRef returns the id of the ref with hash, adding it if it doesn't exist.
+func addRef(ctx context.Context, tx *sql.Tx, hash data.Hash) (int64, error) {
+	id, ok, err := findRef(ctx, tx, hash)
+	if err != nil || ok {
+		return id, err
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO refs (hash, hash_key) VALUES (?, ?)`, hash, hash.Key())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// addSrcItem adds item to the ref, like URef.AddSrc.
+func addSrcItem(ctx context.Context, tx *sql.Tx, refID int64, item index.SrcItem) (bool, error) {
+	var id int64
+	var modifiedAt sql.NullString
+	err := tx.QueryRowContext(ctx,
+		`SELECT id, modified_at FROM src_items WHERE ref_id = ? AND src_id = ? AND data_uri = ? AND meta_uri = ?`,
+		refID, string(item.SrcID), item.DataURI, item.MetaURI).Scan(&id, &modifiedAt)
+	if err == sql.ErrNoRows {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO src_items (ref_id, src_id, data_uri, meta_uri, modified_at) VALUES (?, ?, ?, ?, ?)`,
+			refID, string(item.SrcID), item.DataURI, item.MetaURI, formatTime(item.ModifiedAt))
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	existing := item
+	if existing.ModifiedAt, err = parseTime(modifiedAt); err != nil {
+		return false, err
+	}
+	if existing.Equal(item) {
+		return false, nil
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE src_items SET modified_at = ? WHERE id = ?`,
+		formatTime(item.ModifiedAt), id)
+	return err == nil, err
+}
+
+// addDstItem adds item to the ref, like URef.AddDst.
+func addDstItem(ctx context.Context, tx *sql.Tx, refID int64, item index.DstItem) (bool, error) {
+	var id int64
+	var storedAt, updatedAt, verifiedAt sql.NullString
+	existing := item
+	err := tx.QueryRowContext(ctx,
+		`SELECT id, data_size, meta_size, stored_at, updated_at, verified_at FROM dst_items WHERE ref_id = ? AND dst_id = ? AND data_uri = ? AND meta_uri = ?`,
+		refID, string(item.DstID), item.DataURI, item.MetaURI).Scan(&id, &existing.DataSize, &existing.MetaSize, &storedAt, &updatedAt, &verifiedAt)
+	if err == sql.ErrNoRows {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO dst_items (ref_id, dst_id, data_uri, meta_uri, data_type, data_size, meta_size, stored_at, updated_at, verified_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			refID, string(item.DstID), item.DataURI, item.MetaURI, item.DataType, item.DataSize, item.MetaSize, formatTime(item.StoredAt), formatTime(item.UpdatedAt), formatTime(item.VerifiedAt))
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	if existing.StoredAt, err = parseTime(storedAt); err != nil {
+		return false, err
+	}
+	if existing.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return false, err
+	}
+	if existing.VerifiedAt, err = parseTime(verifiedAt); err != nil {
+		return false, err
+	}
+	if item.VerifiedAt.IsZero() {
+		item.VerifiedAt = existing.VerifiedAt
+	}
+	if existing.Equal(item) {
+		return false, nil
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE dst_items SET data_type = ?, data_size = ?, meta_size = ?, stored_at = ?, updated_at = ?, verified_at = ? WHERE id = ?`,
+		item.DataType, item.DataSize, item.MetaSize, formatTime(item.StoredAt), formatTime(item.UpdatedAt), formatTime(item.VerifiedAt), id)
+	return err == nil, err
+}
+
+// getRef reads the ref with id.
+func getRef(ctx context.Context, q querier, id int64) (*index.URef, error) {
+	uref := &index.URef{}
+	err := q.QueryRowContext(ctx, `SELECT hash FROM refs WHERE id = ?`, id).Scan(&uref.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadItems(ctx, q, id, uref); err != nil {
+		return nil, err
+	}
+	return uref, nil
+}
+
+// loadItems reads the Aliases, SrcItems and DstItems of the ref with id into
+// uref.
+func loadItems(ctx context.Context, q querier, id int64, uref *index.URef) error {
+	rows, err := q.QueryContext(ctx,
+		`SELECT hash FROM ref_hashes WHERE ref_id = ? ORDER BY id`,
+		id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alias data.Hash
+		if err := rows.Scan(&alias); err != nil {
+			return err
+		}
+		uref.Aliases = append(uref.Aliases, alias)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	rows, err = q.QueryContext(ctx,
+		`SELECT src_id, data_uri, meta_uri, modified_at FROM src_items WHERE ref_id = ? ORDER BY id`,
+		id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item index.SrcItem
+		var modifiedAt sql.NullString
+		if err := rows.Scan(&item.SrcID, &item.DataURI, &item.MetaURI, &modifiedAt); err != nil {
+			return err
+		}
+		if item.ModifiedAt, err = parseTime(modifiedAt); err != nil {
+			return err
+		}
+		uref.Srcs = append(uref.Srcs, item)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	rows, err = q.QueryContext(ctx,
+		`SELECT dst_id, data_uri, meta_uri, data_type, data_size, meta_size, stored_at, updated_at, verified_at FROM dst_items WHERE ref_id = ? ORDER BY id`,
+		id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item index.DstItem
+		var storedAt, updatedAt, verifiedAt sql.NullString
+		if err := rows.Scan(&item.DstID, &item.DataURI, &item.MetaURI, &item.DataType, &item.DataSize, &item.MetaSize, &storedAt, &updatedAt, &verifiedAt); err != nil {
+			return err
+		}
+		if item.StoredAt, err = parseTime(storedAt); err != nil {
+			return err
+		}
+		if item.UpdatedAt, err = parseTime(updatedAt); err != nil {
+			return err
+		}
+		if item.VerifiedAt, err = parseTime(verifiedAt); err != nil {
+			return err
+		}
+		uref.Dsts = append(uref.Dsts, item)
+	}
+	return rows.Err()
+}
+
+// removeOrphan removes the ref with id if it has no DstItems, and no
+// SrcItems either if keepSrcs is true.
+func removeOrphan(ctx context.Context, tx *sql.Tx, id int64, keepSrcs bool) error {
+	var srcs, dsts int
+	err := tx.QueryRowContext(ctx,
+		`SELECT (SELECT COUNT(*) FROM src_items WHERE ref_id = ?), (SELECT COUNT(*) FROM dst_items WHERE ref_id = ?)`,
+		id, id).Scan(&srcs, &dsts)
+	if err != nil {
+		return err
+	}
+	if dsts > 0 || (keepSrcs && srcs > 0) {
+		return nil
+	}
+	return removeRef(ctx, tx, id)
+}
+
+// removeRef removes the ref with id and all of its items.
+func removeRef(ctx context.Context, tx *sql.Tx, id int64) error {
+	for _, query := range []string{
+		`DELETE FROM src_items WHERE ref_id = ?`,
+		`DELETE FROM dst_items WHERE ref_id = ?`,
+		`DELETE FROM ref_hashes WHERE ref_id = ?`,
+		`DELETE FROM refs WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queryIDs(ctx context.Context, q querier, query string, args ...interface{}) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func affected(res sql.Result) (bool, error) {
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// formatTime returns the value stored for t. Times are stored as RFC 3339
+// text, and the zero time as NULL.
+func formatTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseTime(s sql.NullString) (time.Time, error) {
+	if !s.Valid {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s.String)
+}
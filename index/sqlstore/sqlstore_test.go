@@ -0,0 +1,169 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/index"
+	"github.com/recentralized/structure/index/storetest"
+	"github.com/recentralized/structure/uri"
+	_ "modernc.org/sqlite"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	dir, err := ioutil.TempDir("", "sqlstore")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	db, err := sql.Open("sqlite", filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %s", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		os.RemoveAll(dir)
+	})
+	return db
+}
+
+func newStore(t *testing.T) *Store {
+	s, err := New(context.Background(), openDB(t))
+	if err != nil {
+		t.Fatalf("New() failed: %s", err)
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) index.Store {
+		return newStore(t)
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+
+	// Start from the first version, as if created by an older release.
+	if err := migrateTo(ctx, db, 1); err != nil {
+		t.Fatalf("migrateTo(1) failed: %s", err)
+	}
+	if got, err := Version(ctx, db); err != nil || got != 1 {
+		t.Fatalf("Version() got %d, %v want 1", got, err)
+	}
+
+	for n := 0; n < 2; n++ {
+		if err := Migrate(ctx, db); err != nil {
+			t.Fatalf("Migrate() #%d failed: %s", n, err)
+		}
+		if got, err := Version(ctx, db); err != nil || got != SchemaVersion {
+			t.Fatalf("Version() #%d got %d, %v want %d", n, got, err, SchemaVersion)
+		}
+	}
+	var indexes int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name LIKE '%_data_uri'`).Scan(&indexes); err != nil {
+		t.Fatalf("failed to count indexes: %s", err)
+	}
+	if got, want := indexes, 2; got != want {
+		t.Errorf("data uri indexes got %d want %d", got, want)
+	}
+
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, SchemaVersion+1, time.Now().Format(time.RFC3339)); err != nil {
+		t.Fatalf("failed to add version: %s", err)
+	}
+	if _, err := New(ctx, db); err == nil {
+		t.Errorf("New() with a newer schema must fail")
+	}
+}
+
+func TestFind(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+	src := index.NewSrc(uri.TrustedNew("file:///a/"))
+	dst := index.NewDstAllAt(uri.TrustedNew("file:///x/"))
+	ref := func(hash, name string, modified time.Time) index.Ref {
+		return index.Ref{
+			Hash: data.LiteralHash(hash),
+			Src: index.SrcItem{
+				SrcID:      src.SrcID,
+				DataURI:    uri.TrustedNew("file:///a/" + name),
+				ModifiedAt: modified,
+			},
+			Dst: index.DstItem{
+				DstID:    dst.DstID,
+				DataURI:  uri.TrustedNew(hash + ".jpg"),
+				DataType: data.Stored{Type: data.JPG},
+				StoredAt: modified,
+			},
+		}
+	}
+	t1 := time.Date(2018, 1, 2, 3, 4, 5, 6, time.UTC)
+	t2 := time.Date(2019, 1, 2, 3, 4, 5, 0, time.FixedZone("X", 3600))
+	for _, r := range []index.Ref{
+		ref("a", "1.jpg", t1),
+		ref("b", "1.jpg", t2),
+		ref("c", "2.jpg", t1),
+	} {
+		if _, err := s.AddRef(ctx, r); err != nil {
+			t.Fatalf("AddRef() failed: %s", err)
+		}
+	}
+
+	urefs, err := s.FindBySrcDataURI(ctx, uri.TrustedNew("file:///a/1.jpg"))
+	if err != nil {
+		t.Fatalf("FindBySrcDataURI() failed: %s", err)
+	}
+	if len(urefs) != 2 || urefs[0].Hash.String() != "a" || urefs[1].Hash.String() != "b" {
+		t.Errorf("FindBySrcDataURI() got %v want refs a and b", urefs)
+	}
+	if len(urefs) == 2 && !urefs[1].Srcs[0].ModifiedAt.Equal(t2) {
+		t.Errorf("ModifiedAt got %s want %s", urefs[1].Srcs[0].ModifiedAt, t2)
+	}
+	urefs, err = s.FindBySrcDataURI(ctx, uri.TrustedNew("file:///a/3.jpg"))
+	if err != nil || len(urefs) != 0 {
+		t.Errorf("FindBySrcDataURI() missing got %v, %v want none", urefs, err)
+	}
+
+	uref, ok, err := s.FindByDstDataURI(ctx, dst.DstID, uri.TrustedNew("c.jpg"))
+	if err != nil || !ok {
+		t.Fatalf("FindByDstDataURI() got %t, %v want found", ok, err)
+	}
+	if got, want := uref.Hash.String(), "c"; got != want {
+		t.Errorf("FindByDstDataURI() got %s want %s", got, want)
+	}
+	if !uref.Dsts[0].StoredAt.Equal(t1) {
+		t.Errorf("StoredAt got %s want %s", uref.Dsts[0].StoredAt, t1)
+	}
+	if _, ok, err := s.FindByDstDataURI(ctx, "other", uri.TrustedNew("c.jpg")); err != nil || ok {
+		t.Errorf("FindByDstDataURI() in another dst got %t, %v want not found", ok, err)
+	}
+}
+
+func TestRefsPages(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+	want := pageSize*2 + 1
+	for n := 0; n < want; n++ {
+		ref := index.Ref{Hash: data.LiteralHash(fmt.Sprintf("h%d", n))}
+		if _, err := s.AddRef(ctx, ref); err != nil {
+			t.Fatalf("AddRef() failed: %s", err)
+		}
+	}
+	var got int
+	if err := s.Refs(ctx, func(uref *index.URef) error {
+		got++
+		return nil
+	}); err != nil {
+		t.Fatalf("Refs() failed: %s", err)
+	}
+	if got != want {
+		t.Errorf("Refs() got %d refs want %d", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package index
+
+// Totals are the number of refs and items in a group, and the bytes they use.
+type Totals struct {
+
+	// Refs is the number of distinct refs.
+	Refs int `json:"refs"`
+
+	// Items is the number of items. It's more than Refs where a ref has
+	// several items in the group.
+	Items int `json:"items"`
+
+	// DataBytes and MetaBytes are the sums of DataSize and MetaSize.
+	DataBytes int64 `json:"data_bytes"`
+	MetaBytes int64 `json:"meta_bytes"`
+}
+
+// Stats summarize the contents of an Index, such as to account for storage.
+// It's suitable for JSON encoding.
+type Stats struct {
+
+	// Refs is the number of refs in the index.
+	Refs int `json:"refs"`
+
+	// Stored totals every DstItem.
+	Stored Totals `json:"stored"`
+
+	// Dsts totals the DstItems in each destination.
+	Dsts map[DstID]Totals `json:"dsts"`
+
+	// Srcs totals the SrcItems found in each source. Since a SrcItem has
+	// no size, the bytes are those of the ref's first DstItem, counted
+	// once for each ref.
+	Srcs map[SrcID]Totals `json:"srcs"`
+
+	// Types totals the DstItems by their DataType, such as "jpg".
+	Types map[string]Totals `json:"types"`
+
+	// Years totals the DstItems by the year of their StoredAt. Items
+	// without a StoredAt are in year 0.
+	Years map[int]Totals `json:"years"`
+
+	// SingleCopy is the number of refs stored in only one destination.
+	// They're lost if that destination is.
+	SingleCopy int `json:"single_copy"`
+
+	// Unstored is the number of refs not stored in any destination.
+	Unstored int `json:"unstored"`
+
+	// Duplicates is the number of refs found at more than one source
+	// URI, and DuplicateURIs is the number of URIs beyond the first.
+	Duplicates    int `json:"duplicates"`
+	DuplicateURIs int `json:"duplicate_uris"`
+}
+
+// Stats calculates statistics of the index.
+func (i *Index) Stats() Stats {
+	s := Stats{
+		Refs:  len(i.Refs),
+		Dsts:  make(map[DstID]Totals),
+		Srcs:  make(map[SrcID]Totals),
+		Types: make(map[string]Totals),
+		Years: make(map[int]Totals),
+	}
+	for _, uref := range i.Refs {
+		var (
+			dsts  = make(map[DstID]bool)
+			types = make(map[string]bool)
+			years = make(map[int]bool)
+		)
+		for n, d := range uref.Dsts {
+			s.Stored = s.Stored.add(n == 0, d.DataSize, d.MetaSize)
+			s.Dsts[d.DstID] = s.Dsts[d.DstID].add(!dsts[d.DstID], d.DataSize, d.MetaSize)
+			dsts[d.DstID] = true
+			typ := d.DataType.String()
+			s.Types[typ] = s.Types[typ].add(!types[typ], d.DataSize, d.MetaSize)
+			types[typ] = true
+			var year int
+			if !d.StoredAt.IsZero() {
+				year = d.StoredAt.Year()
+			}
+			s.Years[year] = s.Years[year].add(!years[year], d.DataSize, d.MetaSize)
+			years[year] = true
+		}
+		switch len(dsts) {
+		case 0:
+			s.Unstored++
+		case 1:
+			s.SingleCopy++
+		}
+
+		var dataSize, metaSize int64
+		if len(uref.Dsts) > 0 {
+			dataSize, metaSize = uref.Dsts[0].DataSize, uref.Dsts[0].MetaSize
+		}
+		srcs := make(map[SrcID]bool)
+		uris := make(map[string]bool)
+		for _, src := range uref.Srcs {
+			if srcs[src.SrcID] {
+				s.Srcs[src.SrcID] = s.Srcs[src.SrcID].add(false, 0, 0)
+			} else {
+				s.Srcs[src.SrcID] = s.Srcs[src.SrcID].add(true, dataSize, metaSize)
+			}
+			srcs[src.SrcID] = true
+			uris[src.DataURI.String()] = true
+		}
+		if len(uris) > 1 {
+			s.Duplicates++
+			s.DuplicateURIs += len(uris) - 1
+		}
+	}
+	return s
+}
+
+// add counts an item with the given sizes, and its ref if it's the ref's
+// first item in the group.
+func (t Totals) add(newRef bool, dataSize, metaSize int64) Totals {
+	if newRef {
+		t.Refs++
+	}
+	t.Items++
+	t.DataBytes += dataSize
+	t.MetaBytes += metaSize
+	return t
+}
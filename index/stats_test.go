@@ -0,0 +1,86 @@
+package index
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/uri"
+)
+
+func TestIndexStats(t *testing.T) {
+	t2017 := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	t2018 := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	jpg := data.Stored{Type: data.JPG}
+	png := data.Stored{Type: data.PNG}
+	dstItem := func(dstID, name string, typ data.Stored, size int64, stored time.Time) DstItem {
+		return DstItem{
+			DstID:    DstID(dstID),
+			DataURI:  uri.TrustedNew(name),
+			DataType: typ,
+			DataSize: size,
+			MetaSize: 1,
+			StoredAt: stored,
+		}
+	}
+	srcItem := func(srcID, name string) SrcItem {
+		return SrcItem{SrcID: SrcID(srcID), DataURI: uri.TrustedNew(name)}
+	}
+	idx := &Index{
+		Refs: []*URef{
+			{
+				Hash: data.LiteralHash("a"),
+				Srcs: []SrcItem{srcItem("s1", "a1"), srcItem("s1", "a2"), srcItem("s2", "a1")},
+				Dsts: []DstItem{dstItem("d1", "a", jpg, 100, t2017), dstItem("d2", "a", jpg, 100, t2018)},
+			},
+			{
+				Hash: data.LiteralHash("b"),
+				Srcs: []SrcItem{srcItem("s1", "b")},
+				Dsts: []DstItem{dstItem("d1", "b", png, 10, t2018)},
+			},
+			{
+				Hash: data.LiteralHash("c"),
+				Srcs: []SrcItem{srcItem("s2", "c1"), srcItem("s2", "c2"), srcItem("s2", "c3")},
+			},
+		},
+	}
+	want := Stats{
+		Refs:   3,
+		Stored: Totals{Refs: 2, Items: 3, DataBytes: 210, MetaBytes: 3},
+		Dsts: map[DstID]Totals{
+			"d1": {Refs: 2, Items: 2, DataBytes: 110, MetaBytes: 2},
+			"d2": {Refs: 1, Items: 1, DataBytes: 100, MetaBytes: 1},
+		},
+		Srcs: map[SrcID]Totals{
+			"s1": {Refs: 2, Items: 3, DataBytes: 110, MetaBytes: 2},
+			"s2": {Refs: 2, Items: 4, DataBytes: 100, MetaBytes: 1},
+		},
+		Types: map[string]Totals{
+			"jpg": {Refs: 1, Items: 2, DataBytes: 200, MetaBytes: 2},
+			"png": {Refs: 1, Items: 1, DataBytes: 10, MetaBytes: 1},
+		},
+		Years: map[int]Totals{
+			2017: {Refs: 1, Items: 1, DataBytes: 100, MetaBytes: 1},
+			2018: {Refs: 2, Items: 2, DataBytes: 110, MetaBytes: 2},
+		},
+		SingleCopy:    1,
+		Unstored:      1,
+		Duplicates:    2,
+		DuplicateURIs: 3,
+	}
+	got := idx.Stats()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() got\n%#v\nwant\n%#v", got, want)
+	}
+
+	b, err := json.Marshal(New().Stats())
+	if err != nil {
+		t.Fatalf("Stats() failed to marshal: %s", err)
+	}
+	wantJSON := `{"refs":0,"stored":{"refs":0,"items":0,"data_bytes":0,"meta_bytes":0},"dsts":{},"srcs":{},"types":{},"years":{},"single_copy":0,"unstored":0,"duplicates":0,"duplicate_uris":0}`
+	if string(b) != wantJSON {
+		t.Errorf("Stats() JSON\ngot  %s\nwant %s", b, wantJSON)
+	}
+}
@@ -0,0 +1,187 @@
+package index
+
+import (
+	"context"
+
+	"github.com/recentralized/structure/data"
+)
+
+// Store is the interface for persisting an index. Its methods mirror those of
+// Index, which is the reference for their behavior, with a context and error
+// for implementations that do I/O. A URef returned by a Store must not be
+// modified by the caller.
+//
+// The storetest package provides tests that any implementation should pass.
+type Store interface {
+
+	// AddSrc adds a source. It's idempotent, returning true if the store
+	// was modified.
+	AddSrc(context.Context, Src) (bool, error)
+
+	// GetSrc returns the source with srcID. It returns false if no source
+	// was found.
+	GetSrc(context.Context, SrcID) (Src, bool, error)
+
+	// RemoveSrc removes a source along with every SrcItem found in it,
+	// like Index.RemoveSrc.
+	RemoveSrc(context.Context, SrcID) (bool, error)
+
+	// Srcs calls fn for each source. Iteration stops if fn returns an
+	// error, which is returned.
+	Srcs(ctx context.Context, fn func(Src) error) error
+
+	// AddDst adds a destination. It's idempotent, returning true if the
+	// store was modified.
+	AddDst(context.Context, Dst) (bool, error)
+
+	// GetDst returns the destination with dstID. It returns false if no
+	// destination was found.
+	GetDst(context.Context, DstID) (Dst, bool, error)
+
+	// RemoveDst removes a destination along with every DstItem stored in
+	// it, like Index.RemoveDst.
+	RemoveDst(context.Context, DstID, OrphanPolicy) (bool, error)
+
+	// Dsts calls fn for each destination. Iteration stops if fn returns
+	// an error, which is returned.
+	Dsts(ctx context.Context, fn func(Dst) error) error
+
+	// AddRef adds a ref. It's idempotent, returning true if the store was
+	// modified.
+	AddRef(context.Context, Ref) (bool, error)
+
+	// AddAlias adds alias as another hash of the ref with hash, like
+	// Index.AddAlias. It returns true if the store was modified.
+	AddAlias(ctx context.Context, hash, alias data.Hash) (bool, error)
+
+	// GetRef returns the URef with hash, which may be its Hash or any of
+	// its Aliases. It returns false if no ref was found.
+	GetRef(context.Context, data.Hash) (*URef, bool, error)
+
+	// RemoveRef removes the ref with hash. It returns true if the store
+	// was modified.
+	RemoveRef(context.Context, data.Hash) (bool, error)
+
+	// Refs calls fn for each ref. Iteration stops if fn returns an error,
+	// which is returned.
+	Refs(ctx context.Context, fn func(*URef) error) error
+}
+
+// NewMemoryStore initializes a Store that keeps idx in memory. It's not safe
+// for concurrent use.
+func NewMemoryStore(idx *Index) Store {
+	return memoryStore{idx}
+}
+
+type memoryStore struct {
+	idx *Index
+}
+
+func (s memoryStore) AddSrc(ctx context.Context, src Src) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.idx.AddSrc(src), nil
+}
+
+func (s memoryStore) GetSrc(ctx context.Context, srcID SrcID) (Src, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return Src{}, false, err
+	}
+	src, ok := s.idx.GetSrc(srcID)
+	return src, ok, nil
+}
+
+func (s memoryStore) RemoveSrc(ctx context.Context, srcID SrcID) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.idx.RemoveSrc(srcID), nil
+}
+
+func (s memoryStore) Srcs(ctx context.Context, fn func(Src) error) error {
+	for _, src := range s.idx.Srcs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s memoryStore) AddDst(ctx context.Context, dst Dst) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.idx.AddDst(dst), nil
+}
+
+func (s memoryStore) GetDst(ctx context.Context, dstID DstID) (Dst, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return Dst{}, false, err
+	}
+	dst, ok := s.idx.GetDst(dstID)
+	return dst, ok, nil
+}
+
+func (s memoryStore) RemoveDst(ctx context.Context, dstID DstID, orphans OrphanPolicy) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.idx.RemoveDst(dstID, orphans), nil
+}
+
+func (s memoryStore) Dsts(ctx context.Context, fn func(Dst) error) error {
+	for _, dst := range s.idx.Dsts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s memoryStore) AddRef(ctx context.Context, ref Ref) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.idx.AddRef(ref), nil
+}
+
+func (s memoryStore) AddAlias(ctx context.Context, hash, alias data.Hash) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.idx.AddAlias(hash, alias), nil
+}
+
+func (s memoryStore) GetRef(ctx context.Context, hash data.Hash) (*URef, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	uref, ok := s.idx.GetRef(hash)
+	return uref, ok, nil
+}
+
+func (s memoryStore) RemoveRef(ctx context.Context, hash data.Hash) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.idx.RemoveRef(hash), nil
+}
+
+func (s memoryStore) Refs(ctx context.Context, fn func(*URef) error) error {
+	for _, uref := range s.idx.Refs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(uref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package index_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/dst"
+	"github.com/recentralized/structure/index"
+	"github.com/recentralized/structure/index/storetest"
+	"github.com/recentralized/structure/uri"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) index.Store {
+		return index.NewMemoryStore(index.New())
+	})
+}
+
+func TestFilesystemStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) index.Store {
+		dir := tempDir(t)
+		s, err := index.NewFilesystemStore(dir, dst.NewFilesystemLayout())
+		if err != nil {
+			t.Fatalf("NewFilesystemStore() failed: %s", err)
+		}
+		return s
+	})
+}
+
+// shardedLayout stores refs in a document per first character of the hash.
+type shardedLayout struct {
+	dst.Layout
+}
+
+func (l shardedLayout) RefsURI(hash data.Hash) uri.URI {
+	return uri.TrustedNew("refs/" + hash.String()[:1] + ".json")
+}
+
+func TestFilesystemStoreSharded(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) index.Store {
+		s, err := index.NewFilesystemStore(tempDir(t), shardedLayout{dst.NewFilesystemLayout()})
+		if err != nil {
+			t.Fatalf("NewFilesystemStore() failed: %s", err)
+		}
+		return s
+	})
+}
+
+func TestFilesystemStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		desc   string
+		layout dst.Layout
+		files  []string
+	}{
+		{
+			desc:   "standard layout",
+			layout: dst.NewFilesystemLayout(),
+			files:  []string{"index.json"},
+		},
+		{
+			desc:   "sharded layout",
+			layout: shardedLayout{dst.NewFilesystemLayout()},
+			files:  []string{"index.json", "refs/a.json", "refs/b.json"},
+		},
+	}
+	for _, tt := range tests {
+		dir := tempDir(t)
+		s, err := index.NewFilesystemStore(dir, tt.layout)
+		if err != nil {
+			t.Fatalf("%q NewFilesystemStore() failed: %s", tt.desc, err)
+		}
+		want := index.New()
+		src := index.NewSrc(uri.TrustedNew("file:///a/"))
+		dstA := index.NewDstAllAt(uri.TrustedNew("file:///b/"))
+		want.AddSrc(src)
+		want.AddDst(dstA)
+		s.AddSrc(ctx, src)
+		s.AddDst(ctx, dstA)
+		for _, h := range []string{"aaaa", "bbbb", "abcd"} {
+			ref := index.Ref{
+				Hash: data.LiteralHash(h),
+				Src:  index.SrcItem{SrcID: src.SrcID, DataURI: uri.TrustedNew(h)},
+				Dst:  index.DstItem{DstID: dstA.DstID, DataURI: uri.TrustedNew(h)},
+			}
+			want.AddRef(ref)
+			if _, err := s.AddRef(ctx, ref); err != nil {
+				t.Fatalf("%q AddRef() failed: %s", tt.desc, err)
+			}
+		}
+		want.AddAlias(data.LiteralHash("aaaa"), data.LiteralHash("zzzz"))
+		if _, err := s.AddAlias(ctx, data.LiteralHash("aaaa"), data.LiteralHash("zzzz")); err != nil {
+			t.Fatalf("%q AddAlias() failed: %s", tt.desc, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "index.json.journal")); err != nil {
+			t.Errorf("%q expected refs in the journal: %s", tt.desc, err)
+		}
+
+		// Reopening writes the journal out to the documents.
+		s, err = index.NewFilesystemStore(dir, tt.layout)
+		if err != nil {
+			t.Fatalf("%q reopen failed: %s", tt.desc, err)
+		}
+		for _, f := range tt.files {
+			if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+				t.Errorf("%q expected file %s: %s", tt.desc, f, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, "index.json.journal")); !os.IsNotExist(err) {
+			t.Errorf("%q journal must be removed: %v", tt.desc, err)
+		}
+		if _, ok, _ := s.GetRef(ctx, data.LiteralHash("zzzz")); !ok {
+			t.Errorf("%q GetRef() by alias after reopen failed", tt.desc)
+		}
+		for _, uref := range want.Refs {
+			got, ok, err := s.GetRef(ctx, uref.Hash)
+			if err != nil || !ok {
+				t.Fatalf("%q GetRef(%s) after reopen got %t, %v", tt.desc, uref.Hash, ok, err)
+			}
+			g, _ := json.Marshal(got)
+			w, _ := json.Marshal(uref)
+			if string(g) != string(w) {
+				t.Errorf("%q GetRef(%s) after reopen\ngot  %s\nwant %s", tt.desc, uref.Hash, g, w)
+			}
+		}
+	}
+
+	// The standard layout writes the same document as the Index.
+	dir := tempDir(t)
+	s, _ := index.NewFilesystemStore(dir, dst.NewFilesystemLayout())
+	want := index.New()
+	src := index.NewSrc(uri.TrustedNew("file:///a/"))
+	want.AddSrc(src)
+	s.AddSrc(ctx, src)
+	got, err := ioutil.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		t.Fatalf("failed to read index: %s", err)
+	}
+	if w, _ := json.Marshal(want); string(got) != string(w) {
+		t.Errorf("index.json\ngot  %s\nwant %s", got, w)
+	}
+}
+
+func TestFilesystemStoreCompact(t *testing.T) {
+	ctx := context.Background()
+	dir := tempDir(t)
+	journal := filepath.Join(dir, "index.json.journal")
+	s, _ := index.NewFilesystemStore(dir, dst.NewFilesystemLayout())
+	addRef := func(h string) {
+		t.Helper()
+		ref := index.Ref{
+			Hash: data.LiteralHash(h),
+			Src:  index.SrcItem{SrcID: index.SrcID("s"), DataURI: uri.TrustedNew(h)},
+		}
+		if _, err := s.AddRef(ctx, ref); err != nil {
+			t.Fatalf("AddRef(%s) failed: %s", h, err)
+		}
+	}
+	for n := 0; n < 1000; n++ {
+		addRef(fmt.Sprintf("%04d", n))
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("journal must be compacted: %v", err)
+	}
+	idx, err := index.ParseJSON(mustOpen(t, filepath.Join(dir, "index.json")))
+	if err != nil {
+		t.Fatalf("failed to read index: %s", err)
+	}
+	if got, want := len(idx.Refs), 1000; got != want {
+		t.Errorf("index.json got %d refs want %d", got, want)
+	}
+
+	// A removed ref isn't added back by the journal.
+	addRef("x")
+	if ok, err := s.RemoveRef(ctx, data.LiteralHash("x")); !ok || err != nil {
+		t.Fatalf("RemoveRef() got %t, %v want true", ok, err)
+	}
+	s, err = index.NewFilesystemStore(dir, dst.NewFilesystemLayout())
+	if err != nil {
+		t.Fatalf("reopen failed: %s", err)
+	}
+	if _, ok, _ := s.GetRef(ctx, data.LiteralHash("x")); ok {
+		t.Errorf("GetRef(x) must not find the removed ref")
+	}
+}
+
+func mustOpen(t *testing.T, path string) *os.File {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open: %s", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "structure-index")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
@@ -0,0 +1,359 @@
+// Package storetest implements tests for implementations of index.Store. An
+// implementation, in this module or elsewhere, runs them from its own tests:
+//
+//	func TestStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) index.Store {
+//			return newEmptyStore(t)
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/index"
+	"github.com/recentralized/structure/uri"
+)
+
+// Run tests a Store. newStore must return a new, empty Store each time it's
+// called.
+func Run(t *testing.T, newStore func(t *testing.T) index.Store) {
+	t.Run("Srcs", func(t *testing.T) { testSrcs(t, newStore(t)) })
+	t.Run("Dsts", func(t *testing.T) { testDsts(t, newStore(t)) })
+	t.Run("Refs", func(t *testing.T) { testRefs(t, newStore(t)) })
+	t.Run("Aliases", func(t *testing.T) { testAliases(t, newStore(t)) })
+	t.Run("RemoveSrc", func(t *testing.T) { testRemoveSrc(t, newStore(t)) })
+	t.Run("RemoveDst", func(t *testing.T) { testRemoveDst(t, newStore(t)) })
+	t.Run("Iterate", func(t *testing.T) { testIterate(t, newStore(t)) })
+	t.Run("Context", func(t *testing.T) { testContext(t, newStore(t)) })
+}
+
+var (
+	srcA = index.NewSrc(uri.TrustedNew("file:///a/"))
+	srcB = index.NewSrc(uri.TrustedNew("file:///b/"))
+	dstA = index.NewDstAllAt(uri.TrustedNew("file:///x/"))
+	dstB = index.NewDstAllAt(uri.TrustedNew("file:///y/"))
+
+	t1 = time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	t2 = time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+func hash(s string) data.Hash {
+	h, err := data.ParseHash(s)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+var (
+	hashA = hash("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	hashB = hash("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	hashC = hash("cccccccccccccccccccccccccccccccccccccccc")
+)
+
+func ref(h data.Hash, src index.Src, dst index.Dst, name string) index.Ref {
+	return index.Ref{
+		Hash: h,
+		Src: index.SrcItem{
+			SrcID:      src.SrcID,
+			DataURI:    uri.TrustedNew("file:///src/" + name),
+			ModifiedAt: t1,
+		},
+		Dst: index.DstItem{
+			DstID:    dst.DstID,
+			DataURI:  uri.TrustedNew("media/" + name),
+			MetaURI:  uri.TrustedNew("meta/" + name + ".json"),
+			DataType: data.Stored{Type: data.JPG},
+			DataSize: 100,
+			MetaSize: 10,
+			StoredAt: t1,
+		},
+	}
+}
+
+func equalJSON(t *testing.T, desc string, got, want interface{}) {
+	t.Helper()
+	g, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("%s: failed to marshal: %s", desc, err)
+	}
+	w, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("%s: failed to marshal: %s", desc, err)
+	}
+	if string(g) != string(w) {
+		t.Errorf("%s\ngot  %s\nwant %s", desc, g, w)
+	}
+}
+
+func must(t *testing.T, desc string, ok bool, err error, want bool) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s failed: %s", desc, err)
+	}
+	if ok != want {
+		t.Errorf("%s got %t want %t", desc, ok, want)
+	}
+}
+
+func testSrcs(t *testing.T, s index.Store) {
+	ctx := context.Background()
+	_, ok, err := s.GetSrc(ctx, srcA.SrcID)
+	must(t, "GetSrc() before add", ok, err, false)
+	ok, err = s.AddSrc(ctx, srcA)
+	must(t, "AddSrc()", ok, err, true)
+	ok, err = s.AddSrc(ctx, srcA)
+	must(t, "AddSrc() again", ok, err, false)
+	got, ok, err := s.GetSrc(ctx, srcA.SrcID)
+	must(t, "GetSrc()", ok, err, true)
+	equalJSON(t, "GetSrc()", got, srcA)
+	ok, err = s.RemoveSrc(ctx, srcA.SrcID)
+	must(t, "RemoveSrc()", ok, err, true)
+	ok, err = s.RemoveSrc(ctx, srcA.SrcID)
+	must(t, "RemoveSrc() again", ok, err, false)
+	_, ok, err = s.GetSrc(ctx, srcA.SrcID)
+	must(t, "GetSrc() after remove", ok, err, false)
+}
+
+func testDsts(t *testing.T, s index.Store) {
+	ctx := context.Background()
+	_, ok, err := s.GetDst(ctx, dstA.DstID)
+	must(t, "GetDst() before add", ok, err, false)
+	ok, err = s.AddDst(ctx, dstA)
+	must(t, "AddDst()", ok, err, true)
+	ok, err = s.AddDst(ctx, dstA)
+	must(t, "AddDst() again", ok, err, false)
+	got, ok, err := s.GetDst(ctx, dstA.DstID)
+	must(t, "GetDst()", ok, err, true)
+	equalJSON(t, "GetDst()", got, dstA)
+	ok, err = s.RemoveDst(ctx, dstA.DstID, index.KeepOrphans)
+	must(t, "RemoveDst()", ok, err, true)
+	ok, err = s.RemoveDst(ctx, dstA.DstID, index.KeepOrphans)
+	must(t, "RemoveDst() again", ok, err, false)
+	_, ok, err = s.GetDst(ctx, dstA.DstID)
+	must(t, "GetDst() after remove", ok, err, false)
+}
+
+func testRefs(t *testing.T, s index.Store) {
+	ctx := context.Background()
+	s.AddSrc(ctx, srcA)
+	s.AddSrc(ctx, srcB)
+	s.AddDst(ctx, dstA)
+
+	_, ok, err := s.GetRef(ctx, hashA)
+	must(t, "GetRef() before add", ok, err, false)
+
+	r1 := ref(hashA, srcA, dstA, "a.jpg")
+	ok, err = s.AddRef(ctx, r1)
+	must(t, "AddRef()", ok, err, true)
+	ok, err = s.AddRef(ctx, r1)
+	must(t, "AddRef() again", ok, err, false)
+
+	r2 := ref(hashA, srcB, dstA, "a.jpg")
+	ok, err = s.AddRef(ctx, r2)
+	must(t, "AddRef() from another src", ok, err, true)
+
+	r3 := r1
+	r3.Src.ModifiedAt = t2
+	r3.Dst.UpdatedAt = t2
+	ok, err = s.AddRef(ctx, r3)
+	must(t, "AddRef() with updated attributes", ok, err, true)
+
+	r4 := r3
+	r4.Dst.VerifiedAt = t2
+	ok, err = s.AddRef(ctx, r4)
+	must(t, "AddRef() verified", ok, err, true)
+	ok, err = s.AddRef(ctx, r3)
+	must(t, "AddRef() without verified time", ok, err, false)
+
+	got, ok, err := s.GetRef(ctx, hashA)
+	must(t, "GetRef()", ok, err, true)
+	want := &index.URef{
+		Hash: hashA,
+		Srcs: []index.SrcItem{r3.Src, r2.Src},
+		Dsts: []index.DstItem{r4.Dst},
+	}
+	equalJSON(t, "GetRef()", got, want)
+
+	ok, err = s.RemoveRef(ctx, hashA)
+	must(t, "RemoveRef()", ok, err, true)
+	ok, err = s.RemoveRef(ctx, hashA)
+	must(t, "RemoveRef() again", ok, err, false)
+	_, ok, err = s.GetRef(ctx, hashA)
+	must(t, "GetRef() after remove", ok, err, false)
+}
+
+func testAliases(t *testing.T, s index.Store) {
+	ctx := context.Background()
+	s.AddSrc(ctx, srcA)
+	s.AddDst(ctx, dstA)
+	r1 := ref(hashA, srcA, dstA, "a.jpg")
+	s.AddRef(ctx, r1)
+	s.AddRef(ctx, ref(hashC, srcA, dstA, "c.jpg"))
+
+	ok, err := s.AddAlias(ctx, hashB, hashA)
+	must(t, "AddAlias() to a missing ref", ok, err, false)
+	ok, err = s.AddAlias(ctx, hashA, hashB)
+	must(t, "AddAlias()", ok, err, true)
+	ok, err = s.AddAlias(ctx, hashA, hashB)
+	must(t, "AddAlias() again", ok, err, false)
+	ok, err = s.AddAlias(ctx, hashA, hashC)
+	must(t, "AddAlias() of another ref's hash", ok, err, false)
+
+	// The ref is found, and added to, by either hash.
+	r2 := ref(hashB, srcA, dstA, "b.jpg")
+	ok, err = s.AddRef(ctx, r2)
+	must(t, "AddRef() by alias", ok, err, true)
+	want := &index.URef{
+		Hash:    hashA,
+		Aliases: []data.Hash{hashB},
+		Srcs:    []index.SrcItem{r1.Src, r2.Src},
+		Dsts:    []index.DstItem{r1.Dst, r2.Dst},
+	}
+	for _, h := range []data.Hash{hashA, hashB} {
+		got, ok, err := s.GetRef(ctx, h)
+		must(t, "GetRef("+h.String()+")", ok, err, true)
+		equalJSON(t, "GetRef("+h.String()+")", got, want)
+	}
+	var refs []*index.URef
+	err = s.Refs(ctx, func(uref *index.URef) error {
+		refs = append(refs, uref)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Refs() failed: %s", err)
+	}
+	if len(refs) != 2 {
+		t.Fatalf("Refs() got %d refs want 2", len(refs))
+	}
+	equalJSON(t, "Refs()", refs[0], want)
+
+	ok, err = s.RemoveRef(ctx, hashB)
+	must(t, "RemoveRef() by alias", ok, err, true)
+	for _, h := range []data.Hash{hashA, hashB} {
+		_, ok, err := s.GetRef(ctx, h)
+		must(t, "GetRef("+h.String()+") after remove", ok, err, false)
+	}
+}
+
+func testRemoveSrc(t *testing.T, s index.Store) {
+	ctx := context.Background()
+	s.AddSrc(ctx, srcA)
+	s.AddSrc(ctx, srcB)
+	s.AddDst(ctx, dstA)
+	s.AddRef(ctx, ref(hashA, srcA, dstA, "a.jpg"))
+	s.AddRef(ctx, ref(hashA, srcB, dstA, "a.jpg"))
+
+	ok, err := s.RemoveSrc(ctx, srcA.SrcID)
+	must(t, "RemoveSrc()", ok, err, true)
+	got, ok, err := s.GetRef(ctx, hashA)
+	must(t, "GetRef()", ok, err, true)
+	want := &index.URef{
+		Hash: hashA,
+		Srcs: []index.SrcItem{ref(hashA, srcB, dstA, "a.jpg").Src},
+		Dsts: []index.DstItem{ref(hashA, srcB, dstA, "a.jpg").Dst},
+	}
+	equalJSON(t, "GetRef() after RemoveSrc()", got, want)
+}
+
+func testRemoveDst(t *testing.T, s index.Store) {
+	ctx := context.Background()
+	s.AddSrc(ctx, srcA)
+	s.AddDst(ctx, dstA)
+	s.AddDst(ctx, dstB)
+	s.AddRef(ctx, ref(hashA, srcA, dstA, "a.jpg"))
+	s.AddRef(ctx, ref(hashA, srcA, dstB, "a.jpg"))
+	s.AddRef(ctx, ref(hashB, srcA, dstA, "b.jpg"))
+	s.AddRef(ctx, ref(hashC, srcA, dstB, "c.jpg"))
+
+	ok, err := s.RemoveDst(ctx, dstA.DstID, index.KeepOrphans)
+	must(t, "RemoveDst(KeepOrphans)", ok, err, true)
+	got, ok, err := s.GetRef(ctx, hashA)
+	must(t, "GetRef(a)", ok, err, true)
+	if len(got.Dsts) != 1 || got.Dsts[0].DstID != dstB.DstID {
+		t.Errorf("GetRef(a) got dsts %v want only %s", got.Dsts, dstB.DstID)
+	}
+	got, ok, err = s.GetRef(ctx, hashB)
+	must(t, "GetRef(b) kept as orphan", ok, err, true)
+	if len(got.Dsts) != 0 || len(got.Srcs) != 1 {
+		t.Errorf("GetRef(b) got %d srcs %d dsts want 1 and 0", len(got.Srcs), len(got.Dsts))
+	}
+
+	ok, err = s.RemoveDst(ctx, dstB.DstID, index.RemoveOrphans)
+	must(t, "RemoveDst(RemoveOrphans)", ok, err, true)
+	_, ok, err = s.GetRef(ctx, hashC)
+	must(t, "GetRef(c) removed as orphan", ok, err, false)
+	_, ok, err = s.GetRef(ctx, hashB)
+	must(t, "GetRef(b) untouched", ok, err, true)
+}
+
+func testIterate(t *testing.T, s index.Store) {
+	ctx := context.Background()
+	s.AddSrc(ctx, srcA)
+	s.AddSrc(ctx, srcB)
+	s.AddDst(ctx, dstA)
+	s.AddDst(ctx, dstB)
+	s.AddRef(ctx, ref(hashA, srcA, dstA, "a.jpg"))
+	s.AddRef(ctx, ref(hashB, srcA, dstA, "b.jpg"))
+	s.AddRef(ctx, ref(hashC, srcA, dstA, "c.jpg"))
+
+	var srcs []index.Src
+	if err := s.Srcs(ctx, func(src index.Src) error {
+		srcs = append(srcs, src)
+		return nil
+	}); err != nil {
+		t.Fatalf("Srcs() failed: %s", err)
+	}
+	equalJSON(t, "Srcs()", srcs, []index.Src{srcA, srcB})
+
+	var dsts []index.Dst
+	if err := s.Dsts(ctx, func(dst index.Dst) error {
+		dsts = append(dsts, dst)
+		return nil
+	}); err != nil {
+		t.Fatalf("Dsts() failed: %s", err)
+	}
+	equalJSON(t, "Dsts()", dsts, []index.Dst{dstA, dstB})
+
+	var hashes []data.Hash
+	if err := s.Refs(ctx, func(uref *index.URef) error {
+		hashes = append(hashes, uref.Hash)
+		return nil
+	}); err != nil {
+		t.Fatalf("Refs() failed: %s", err)
+	}
+	equalJSON(t, "Refs()", hashes, []data.Hash{hashA, hashB, hashC})
+
+	stop := errors.New("stop")
+	var n int
+	err := s.Refs(ctx, func(uref *index.URef) error {
+		n++
+		return stop
+	})
+	if err != stop {
+		t.Errorf("Refs() got error %v want the one returned by fn", err)
+	}
+	if n != 1 {
+		t.Errorf("Refs() called fn %d times after it returned an error", n)
+	}
+}
+
+func testContext(t *testing.T, s index.Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.AddSrc(ctx, srcA); err == nil {
+		t.Errorf("AddSrc() with canceled context must fail")
+	}
+	if _, err := s.AddRef(ctx, ref(hashA, srcA, dstA, "a.jpg")); err == nil {
+		t.Errorf("AddRef() with canceled context must fail")
+	}
+	if _, _, err := s.GetRef(ctx, hashA); err == nil {
+		t.Errorf("GetRef() with canceled context must fail")
+	}
+}
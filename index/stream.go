@@ -0,0 +1,249 @@
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Decoder reads an index document one value at a time, so that an index
+// doesn't have to fit in memory. The document is the same as read by
+// ParseJSON, with the same version checks. The version must come before the
+// srcs, dsts and refs, as it does in documents written by json.Marshal or
+// Encoder. A document whose values come first is read as v0, which has no
+// version, and is rejected if a later version says otherwise.
+type Decoder struct {
+	dec       *json.Decoder
+	started   bool
+	versioned bool
+	assumedV0 bool
+	section   string
+	err       error
+}
+
+// NewDecoder initializes a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(r)}
+}
+
+// Next returns the next value in the index, which is a Src, Dst or *URef. It
+// returns io.EOF at the end of the document. If the document is not at a
+// version that can be transparently upgraded then ErrWrongVersion is
+// returned.
+func (d *Decoder) Next() (interface{}, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	v, err := d.next()
+	if err != nil {
+		d.err = err
+	}
+	return v, err
+}
+
+func (d *Decoder) next() (interface{}, error) {
+	if !d.started {
+		if err := d.expectDelim('{'); err != nil {
+			return nil, err
+		}
+		d.started = true
+	}
+	for {
+		if d.section != "" {
+			if d.dec.More() {
+				return d.decodeValue()
+			}
+			if err := d.expectDelim(']'); err != nil {
+				return nil, err
+			}
+			d.section = ""
+		}
+		if !d.dec.More() {
+			if err := d.expectDelim('}'); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("index: unexpected %v", tok)
+		}
+		switch key {
+		case "version":
+			var v string
+			if err := d.dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			if _, err := upgradeVersion(v); err != nil {
+				return nil, err
+			}
+			if d.assumedV0 && v != versionV0 {
+				return nil, fmt.Errorf("index: version %q must come before srcs, dsts and refs", v)
+			}
+			d.versioned = true
+		case "srcs", "dsts", "refs":
+			if !d.versioned {
+				d.versioned = true
+				d.assumedV0 = true
+			}
+			tok, err := d.dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			if tok == nil {
+				continue
+			}
+			if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+				return nil, fmt.Errorf("index: expected array for %q, got %v", key, tok)
+			}
+			d.section = key
+		default:
+			var skip json.RawMessage
+			if err := d.dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (d *Decoder) decodeValue() (interface{}, error) {
+	switch d.section {
+	case "srcs":
+		var src Src
+		err := d.dec.Decode(&src)
+		return src, err
+	case "dsts":
+		var dst Dst
+		err := d.dec.Decode(&dst)
+		return dst, err
+	default:
+		uref := &URef{}
+		err := d.dec.Decode(uref)
+		return uref, err
+	}
+}
+
+func (d *Decoder) expectDelim(want json.Delim) error {
+	tok, err := d.dec.Token()
+	if err == io.EOF && want != '{' {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("index: expected %s, got %v", want, tok)
+	}
+	return nil
+}
+
+// upgradeVersion returns the current version if an index at version v can be
+// transparently upgraded to it, or ErrWrongVersion.
+func upgradeVersion(v string) (string, error) {
+	switch v {
+	case versionV1:
+	case versionV0:
+	default:
+		return "", ErrWrongVersion
+	}
+	return versionV1, nil
+}
+
+// Encoder writes an index document one value at a time. Values must be
+// encoded in the order that they appear in the document: all of the Srcs,
+// then Dsts, then Refs. The output is identical to json.Marshal of an Index
+// holding the same values.
+type Encoder struct {
+	w       io.Writer
+	section int
+	err     error
+}
+
+// Sections of the document, in order.
+const (
+	sectionNone = iota
+	sectionSrcs
+	sectionDsts
+	sectionRefs
+	sectionClosed
+)
+
+var sectionNames = []string{"", "srcs", "dsts", "refs"}
+
+// ErrEncoderOrder is returned when a value is encoded out of order.
+var ErrEncoderOrder = errors.New("index: values must be encoded as srcs, dsts, then refs")
+
+// NewEncoder initializes an Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// EncodeSrc writes a Src.
+func (e *Encoder) EncodeSrc(src Src) error {
+	return e.encode(sectionSrcs, src)
+}
+
+// EncodeDst writes a Dst.
+func (e *Encoder) EncodeDst(dst Dst) error {
+	return e.encode(sectionDsts, dst)
+}
+
+// EncodeRef writes a URef.
+func (e *Encoder) EncodeRef(uref *URef) error {
+	return e.encode(sectionRefs, uref)
+}
+
+// Close finishes the document. It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if e.err != nil || e.section == sectionClosed {
+		return e.err
+	}
+	e.start(sectionClosed)
+	e.write("}")
+	return e.err
+}
+
+func (e *Encoder) encode(section int, v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	if section < e.section {
+		return ErrEncoderOrder
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if section == e.section {
+		e.write(",")
+	} else {
+		e.start(section)
+		e.write(`,"` + sectionNames[section] + `":[`)
+	}
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+	return e.err
+}
+
+// start ends the current section and moves to the next.
+func (e *Encoder) start(section int) {
+	switch e.section {
+	case sectionNone:
+		e.write(`{"version":"` + Version + `"`)
+	default:
+		e.write("]")
+	}
+	e.section = section
+}
+
+func (e *Encoder) write(s string) {
+	if e.err == nil {
+		_, e.err = io.WriteString(e.w, s)
+	}
+}
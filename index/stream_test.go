@@ -0,0 +1,233 @@
+package index
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/uri"
+)
+
+func streamTestIndexes() []*Index {
+	return []*Index{
+		New(),
+		{
+			Version: Version,
+			Srcs:    []Src{NewSrc(uri.TrustedNew("file:///a/"))},
+		},
+		{
+			Version: Version,
+			Dsts:    []Dst{NewDstAllAt(uri.TrustedNew("file:///b/"))},
+			Refs: []*URef{
+				{
+					Hash: data.LiteralHash("a"),
+					Dsts: []DstItem{{DstID: DstID("d"), DataURI: uri.TrustedNew("a<&>.jpg")}},
+				},
+			},
+		},
+		{
+			Version: Version,
+			Srcs:    []Src{NewSrc(uri.TrustedNew("file:///a/")), NewSrc(uri.TrustedNew("file:///c/"))},
+			Dsts:    []Dst{NewDstAllAt(uri.TrustedNew("file:///b/"))},
+			Refs: []*URef{
+				{
+					Hash:    data.LiteralHash("a"),
+					Aliases: []data.Hash{data.LiteralHash("x")},
+					Srcs: []SrcItem{
+						{SrcID: SrcID("s"), DataURI: uri.TrustedNew("a"), ModifiedAt: time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)},
+					},
+					Dsts: []DstItem{
+						{DstID: DstID("d"), DataURI: uri.TrustedNew("a"), DataType: data.Stored{Type: data.JPG}, DataSize: 10},
+					},
+				},
+				{
+					Hash: data.LiteralHash("b"),
+					Srcs: []SrcItem{{SrcID: SrcID("s"), DataURI: uri.TrustedNew("b")}},
+				},
+			},
+		},
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	for n, idx := range streamTestIndexes() {
+		want, err := json.Marshal(idx)
+		if err != nil {
+			t.Fatalf("%d failed to marshal: %s", n, err)
+		}
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		for _, src := range idx.Srcs {
+			if err := enc.EncodeSrc(src); err != nil {
+				t.Fatalf("%d EncodeSrc() failed: %s", n, err)
+			}
+		}
+		for _, dst := range idx.Dsts {
+			if err := enc.EncodeDst(dst); err != nil {
+				t.Fatalf("%d EncodeDst() failed: %s", n, err)
+			}
+		}
+		for _, uref := range idx.Refs {
+			if err := enc.EncodeRef(uref); err != nil {
+				t.Fatalf("%d EncodeRef() failed: %s", n, err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("%d Close() failed: %s", n, err)
+		}
+		if got, want := buf.String(), string(want); got != want {
+			t.Errorf("%d Encoder\ngot  %s\nwant %s", n, got, want)
+		}
+	}
+}
+
+func TestEncoderOrder(t *testing.T) {
+	enc := NewEncoder(&bytes.Buffer{})
+	if err := enc.EncodeDst(Dst{DstID: DstID("d")}); err != nil {
+		t.Fatalf("EncodeDst() failed: %s", err)
+	}
+	if err := enc.EncodeSrc(Src{SrcID: SrcID("s")}); err != ErrEncoderOrder {
+		t.Errorf("EncodeSrc() after dst got %v want ErrEncoderOrder", err)
+	}
+}
+
+func decodeAll(r io.Reader) (*Index, error) {
+	idx := New()
+	dec := NewDecoder(r)
+	for {
+		v, err := dec.Next()
+		if err == io.EOF {
+			return idx, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case Src:
+			idx.Srcs = append(idx.Srcs, v)
+		case Dst:
+			idx.Dsts = append(idx.Dsts, v)
+		case *URef:
+			idx.Refs = append(idx.Refs, v)
+		}
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	for n, idx := range streamTestIndexes() {
+		b, err := json.Marshal(idx)
+		if err != nil {
+			t.Fatalf("%d failed to marshal: %s", n, err)
+		}
+		got, err := decodeAll(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%d Decoder failed: %s", n, err)
+		}
+		gotJSON, _ := json.Marshal(got)
+		if string(gotJSON) != string(b) {
+			t.Errorf("%d Decoder\ngot  %s\nwant %s", n, gotJSON, b)
+		}
+	}
+}
+
+func TestDecoderV0(t *testing.T) {
+	for _, name := range []string{"v0.json", "v1.json"} {
+		f, err := os.Open("migration/_data/" + name)
+		if err != nil {
+			t.Fatalf("failed to open: %s", err)
+		}
+		defer f.Close()
+		got, err := decodeAll(f)
+		if err != nil {
+			t.Fatalf("%s Decoder failed: %s", name, err)
+		}
+		f.Seek(0, io.SeekStart)
+		want, err := ParseJSON(f)
+		if err != nil {
+			t.Fatalf("%s ParseJSON failed: %s", name, err)
+		}
+		if a, b := got, want; len(a.Srcs) != len(b.Srcs) || len(a.Dsts) != len(b.Dsts) || len(a.Refs) != len(b.Refs) {
+			t.Fatalf("%s Decoder got %d/%d/%d values want %d/%d/%d", name,
+				len(a.Srcs), len(a.Dsts), len(a.Refs), len(b.Srcs), len(b.Dsts), len(b.Refs))
+		}
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("%s Decoder\ngot  %s\nwant %s", name, gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		json    string
+		wantErr error
+	}{
+		{
+			desc:    "wrong version",
+			json:    `{"version":"v9","srcs":[{"src_id":"a"}]}`,
+			wantErr: ErrWrongVersion,
+		},
+		{
+			desc:    "wrong version after values",
+			json:    `{"srcs":[{"src_id":"a"}],"version":"v9"}`,
+			wantErr: ErrWrongVersion,
+		},
+		{
+			desc:    "truncated",
+			json:    `{"version":"v1","srcs":[{"src_id":"a"}`,
+			wantErr: nil,
+		},
+		{
+			desc:    "not an object",
+			json:    `[]`,
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		_, err := decodeAll(strings.NewReader(tt.json))
+		if err == nil {
+			t.Errorf("%q expected an error", tt.desc)
+			continue
+		}
+		if tt.wantErr != nil && err != tt.wantErr {
+			t.Errorf("%q got error %v want %v", tt.desc, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDecoderVersionFirst(t *testing.T) {
+	// Values without a version are v0, even if the document can't be
+	// seeked.
+	r := struct{ io.Reader }{strings.NewReader(`{"srcs":[{"src_id":"a"}],"dsts":[{"dst_id":"b"}]}`)}
+	idx, err := decodeAll(r)
+	if err != nil {
+		t.Fatalf("Decoder failed: %s", err)
+	}
+	if len(idx.Srcs) != 1 || len(idx.Dsts) != 1 {
+		t.Errorf("Decoder got %d srcs and %d dsts want 1 and 1", len(idx.Srcs), len(idx.Dsts))
+	}
+	r = struct{ io.Reader }{strings.NewReader(`{"version":"v1","srcs":[{"src_id":"a"}]}`)}
+	if _, err := NewDecoder(r).Next(); err != nil {
+		t.Errorf("Decoder failed: %s", err)
+	}
+
+	// A later version must be v0 too.
+	for _, doc := range []string{
+		`{"srcs":[{"src_id":"a"}],"version":"v1","dsts":[{"dst_id":"b"}]}`,
+		`{"srcs":[{"src_id":"a"}],"version":"v9"}`,
+	} {
+		if _, err := decodeAll(strings.NewReader(doc)); err == nil {
+			t.Errorf("Decoder must fail for %s", doc)
+		}
+	}
+	if _, err := decodeAll(strings.NewReader(`{"srcs":[{"src_id":"a"}],"version":""}`)); err != nil {
+		t.Errorf("Decoder failed for a later v0 version: %s", err)
+	}
+}
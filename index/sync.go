@@ -0,0 +1,206 @@
+package index
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/recentralized/structure/data"
+)
+
+// syncShards is the number of locks that refs are spread over in a SyncIndex.
+const syncShards = 64
+
+// SyncIndex is an Index that's safe for concurrent use. Refs are split into
+// shards by hash, each with its own lock, so that adding and getting refs of
+// different content rarely contend. Srcs and Dsts share a single lock.
+//
+// Its methods behave like those of Index. Use Index to get a snapshot of
+// everything that has been added, for example to write it out.
+type SyncIndex struct {
+	mu  sync.Mutex
+	idx *Index // Srcs and Dsts only.
+
+	// aliases maps the Key of each alias to the Key of the ref's Hash.
+	// It's only written by NewSyncIndex.
+	aliases map[string]string
+
+	seq    uint64
+	shards [syncShards]syncShard
+}
+
+type syncShard struct {
+	sync.RWMutex
+	refs map[string]*syncRef
+}
+
+// syncRef is a ref along with the order it was added in.
+type syncRef struct {
+	seq  uint64
+	uref *URef
+}
+
+// NewSyncIndex initializes a SyncIndex with the contents of idx. The SyncIndex
+// takes ownership of idx's refs, so idx must not be used afterwards.
+func NewSyncIndex(idx *Index) *SyncIndex {
+	s := &SyncIndex{
+		idx:     New(),
+		aliases: make(map[string]string),
+	}
+	for n := range s.shards {
+		s.shards[n].refs = make(map[string]*syncRef)
+	}
+	for _, src := range idx.Srcs {
+		s.idx.AddSrc(src)
+	}
+	for _, dst := range idx.Dsts {
+		s.idx.AddDst(dst)
+	}
+	// Index the primary hashes first so they take precedence over aliases,
+	// as they do in Index.
+	for _, uref := range idx.Refs {
+		key := uref.Hash.Key()
+		shard := s.shard(key)
+		if _, ok := shard.refs[key]; ok {
+			continue
+		}
+		s.seq++
+		shard.refs[key] = &syncRef{seq: s.seq, uref: uref}
+	}
+	for _, uref := range idx.Refs {
+		for _, a := range uref.Aliases {
+			key := a.Key()
+			if _, ok := s.shard(key).refs[key]; ok {
+				continue
+			}
+			if _, ok := s.aliases[key]; !ok {
+				s.aliases[key] = uref.Hash.Key()
+			}
+		}
+	}
+	return s
+}
+
+// AddSrc adds a source to the index. It's idempotent, returning true if the
+// index was modified.
+func (s *SyncIndex) AddSrc(src Src) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.idx.AddSrc(src)
+}
+
+// AddDst adds a destination to the index. It's idempotent, returning true if
+// the index was modified.
+func (s *SyncIndex) AddDst(dst Dst) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.idx.AddDst(dst)
+}
+
+// GetSrc returns the source with srcID. It returns false if no source was
+// found.
+func (s *SyncIndex) GetSrc(srcID SrcID) (Src, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.idx.GetSrc(srcID)
+}
+
+// GetDst returns the destination with dstID. It returns false if no
+// destination was found.
+func (s *SyncIndex) GetDst(dstID DstID) (Dst, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.idx.GetDst(dstID)
+}
+
+// AddRef adds a ref to the index. It's idempotent, returning true if the
+// index was modified.
+func (s *SyncIndex) AddRef(ref Ref) bool {
+	key := s.key(ref.Hash)
+	shard := s.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+	r, ok := shard.refs[key]
+	if !ok {
+		r = &syncRef{
+			seq:  atomic.AddUint64(&s.seq, 1),
+			uref: &URef{Hash: ref.Hash},
+		}
+		shard.refs[key] = r
+	}
+	addSrc := r.uref.AddSrc(ref.Src)
+	addDst := r.uref.AddDst(ref.Dst)
+	return addSrc || addDst
+}
+
+// GetRef returns a copy of the URef with hash, which may be its Hash or any
+// of its Aliases. It returns false if no ref was found.
+func (s *SyncIndex) GetRef(hash data.Hash) (*URef, bool) {
+	key := s.key(hash)
+	shard := s.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
+	r, ok := shard.refs[key]
+	if !ok {
+		return nil, false
+	}
+	return r.uref.copy(), true
+}
+
+// Index returns a copy of the index's contents. Refs are in the order they
+// were first added.
+func (s *SyncIndex) Index() *Index {
+	s.mu.Lock()
+	idx := New()
+	idx.Srcs = append(idx.Srcs, s.idx.Srcs...)
+	idx.Dsts = append(idx.Dsts, s.idx.Dsts...)
+	s.mu.Unlock()
+
+	var refs []*syncRef
+	for n := range s.shards {
+		shard := &s.shards[n]
+		shard.RLock()
+		for _, r := range shard.refs {
+			refs = append(refs, &syncRef{seq: r.seq, uref: r.uref.copy()})
+		}
+		shard.RUnlock()
+	}
+	sort.Slice(refs, func(a, b int) bool {
+		return refs[a].seq < refs[b].seq
+	})
+	for _, r := range refs {
+		idx.Refs = append(idx.Refs, r.uref)
+	}
+	return idx
+}
+
+// key returns the Key of the ref's Hash that hash belongs to.
+func (s *SyncIndex) key(hash data.Hash) string {
+	key := hash.Key()
+	if primary, ok := s.aliases[key]; ok {
+		return primary
+	}
+	return key
+}
+
+func (s *SyncIndex) shard(key string) *syncShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.shards[h.Sum32()%syncShards]
+}
+
+// copy returns a URef that shares nothing with r.
+func (r *URef) copy() *URef {
+	c := &URef{Hash: r.Hash}
+	if r.Aliases != nil {
+		c.Aliases = append([]data.Hash(nil), r.Aliases...)
+	}
+	if r.Srcs != nil {
+		c.Srcs = append([]SrcItem(nil), r.Srcs...)
+	}
+	if r.Dsts != nil {
+		c.Dsts = append([]DstItem(nil), r.Dsts...)
+	}
+	return c
+}
@@ -0,0 +1,154 @@
+package index
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/recentralized/structure/data"
+	"github.com/recentralized/structure/uri"
+)
+
+func TestSyncIndex(t *testing.T) {
+	idx := newRemoveTestIndex()
+	idx.Refs[1].SetHash(data.LiteralHash("b2"))
+	s := NewSyncIndex(idx)
+
+	if s.AddSrc(Src{SrcID: SrcID("s1")}) {
+		t.Errorf("AddSrc(s1) must be idempotent")
+	}
+	if !s.AddSrc(Src{SrcID: SrcID("s3")}) {
+		t.Errorf("AddSrc(s3) must modify")
+	}
+	if _, ok := s.GetSrc(SrcID("s3")); !ok {
+		t.Errorf("GetSrc(s3) must be ok")
+	}
+	if s.AddDst(Dst{DstID: DstID("d1")}) {
+		t.Errorf("AddDst(d1) must be idempotent")
+	}
+	if _, ok := s.GetDst(DstID("d2")); !ok {
+		t.Errorf("GetDst(d2) must be ok")
+	}
+
+	ref := Ref{
+		Hash: data.LiteralHash("a"),
+		Src:  SrcItem{SrcID: SrcID("s1"), DataURI: uri.TrustedNew("a")},
+		Dst:  DstItem{DstID: DstID("d1"), DataURI: uri.TrustedNew("a")},
+	}
+	if s.AddRef(ref) {
+		t.Errorf("AddRef(a) must be idempotent")
+	}
+	ref.Hash = data.LiteralHash("c")
+	if !s.AddRef(ref) {
+		t.Errorf("AddRef(c) must modify")
+	}
+	uref, ok := s.GetRef(data.LiteralHash("b"))
+	if !ok || !uref.Hash.Equal(data.LiteralHash("b2")) {
+		t.Fatalf("GetRef(b) by alias got %v, %t want b2", uref, ok)
+	}
+	uref.Srcs[0].SrcID = SrcID("changed")
+	if uref, _ := s.GetRef(data.LiteralHash("b2")); uref.Srcs[0].SrcID != SrcID("s1") {
+		t.Errorf("GetRef() must return a copy")
+	}
+
+	got := s.Index()
+	var hashes []string
+	for _, uref := range got.Refs {
+		hashes = append(hashes, uref.Hash.String())
+	}
+	if got, want := fmt.Sprint(hashes), "[a b2 c]"; got != want {
+		t.Errorf("Index() refs got %s want %s", got, want)
+	}
+	if got, want := len(got.Srcs), 3; got != want {
+		t.Errorf("Index() srcs got %d want %d", got, want)
+	}
+}
+
+// TestSyncIndexConcurrent adds and gets overlapping refs from many goroutines.
+// Run it with -race.
+func TestSyncIndexConcurrent(t *testing.T) {
+	const (
+		workers = 8
+		hashes  = 50
+	)
+	s := NewSyncIndex(New())
+	want := New()
+	refs := make([][]Ref, workers)
+	for w := 0; w < workers; w++ {
+		for n := 0; n < hashes; n++ {
+			ref := Ref{
+				Hash: data.LiteralHash(fmt.Sprintf("h%d", n)),
+				Src: SrcItem{
+					SrcID:   SrcID(fmt.Sprintf("s%d", w%2)),
+					DataURI: uri.TrustedNew(fmt.Sprintf("%d/%d", w, n)),
+				},
+				Dst: DstItem{
+					DstID:   DstID("d"),
+					DataURI: uri.TrustedNew(fmt.Sprintf("%d", n)),
+				},
+			}
+			refs[w] = append(refs[w], ref, ref)
+			want.AddRef(ref)
+		}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		changed int
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(refs []Ref) {
+			defer wg.Done()
+			var n int
+			for _, ref := range refs {
+				s.AddSrc(Src{SrcID: ref.Src.SrcID})
+				if s.AddRef(ref) {
+					n++
+				}
+			}
+			mu.Lock()
+			changed += n
+			mu.Unlock()
+		}(refs[w])
+		go func(refs []Ref) {
+			defer wg.Done()
+			for _, ref := range refs {
+				if uref, ok := s.GetRef(ref.Hash); ok && len(uref.Dsts) != 1 {
+					t.Errorf("GetRef(%s) got %d dsts want 1", ref.Hash, len(uref.Dsts))
+				}
+				s.GetSrc(ref.Src.SrcID)
+			}
+			s.Index()
+		}(refs[w])
+	}
+	wg.Wait()
+
+	// Each distinct SrcItem is new exactly once.
+	if got, want := changed, workers*hashes; got != want {
+		t.Errorf("AddRef() modified %d times want %d", got, want)
+	}
+	got := s.Index()
+	if got, want := len(got.Srcs), 2; got != want {
+		t.Errorf("Srcs got %d want %d", got, want)
+	}
+	if got, want := len(got.Refs), len(want.Refs); got != want {
+		t.Fatalf("Refs got %d want %d", got, want)
+	}
+	for _, uref := range want.Refs {
+		g, ok := s.GetRef(uref.Hash)
+		if !ok {
+			t.Errorf("GetRef(%s) not found", uref.Hash)
+			continue
+		}
+		if got, want := len(g.Srcs), len(uref.Srcs); got != want {
+			t.Errorf("GetRef(%s) got %d srcs want %d", uref.Hash, got, want)
+		}
+		for _, src := range uref.Srcs {
+			if !g.RemoveSrc(src) {
+				t.Errorf("GetRef(%s) missing %s", uref.Hash, src)
+			}
+		}
+	}
+}
@@ -59,6 +59,8 @@ func (m *Meta) UnmarshalJSON(data []byte) error {
 type metaContentJSON struct {
 	Created *time.Time `json:"created,omitempty"`
 	Image   *Image     `json:"image,omitempty"`
+	Video   *Video     `json:"video,omitempty"`
+	Audio   *Audio     `json:"audio,omitempty"`
 	Exif    Exif       `json:"exif,omitempty"`
 }
 
@@ -71,6 +73,12 @@ func (m Content) MarshalJSON() ([]byte, error) {
 	if !m.Image.isZero() {
 		j.Image = &m.Image
 	}
+	if !m.Video.isZero() {
+		j.Video = &m.Video
+	}
+	if !m.Audio.isZero() {
+		j.Audio = &m.Audio
+	}
 	if len(m.Exif) != 0 {
 		j.Exif = m.Exif
 	}
@@ -89,6 +97,12 @@ func (m *Content) UnmarshalJSON(data []byte) error {
 	if j.Image != nil {
 		m.Image = *j.Image
 	}
+	if j.Video != nil {
+		m.Video = *j.Video
+	}
+	if j.Audio != nil {
+		m.Audio = *j.Audio
+	}
 	if j.Exif != nil {
 		m.Exif = j.Exif
 	}
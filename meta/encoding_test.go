@@ -47,6 +47,31 @@ func TestMetaJSON(t *testing.T) {
 			},
 			json: `{"version":"v1","type":"jpg","size":100,"inherent":{"created":"0001-02-03T04:05:06.000000007Z","image":{"width":100,"height":60},"exif":{"CreateData":{"id":"0x9004","val":"2013:07:17 19:59:58"}}},"sidecar":{"created":"0002-02-03T04:05:06.000000007Z"}}`,
 		},
+		{
+			desc: "video fields",
+			meta: Meta{
+				Version: "v1",
+				Type:    data.MOV,
+				Inherent: Content{
+					Image: Image{
+						Width:  1920,
+						Height: 1080,
+					},
+					Video: Video{
+						Duration:  90 * time.Second,
+						Codec:     "hevc",
+						FrameRate: 29.97,
+					},
+					Audio: Audio{
+						Duration:   90 * time.Second,
+						Codec:      "aac",
+						SampleRate: 44100,
+						Channels:   2,
+					},
+				},
+			},
+			json: `{"version":"v1","type":"mov","size":0,"inherent":{"image":{"width":1920,"height":1080},"video":{"duration":90000000000,"codec":"hevc","frame_rate":29.97},"audio":{"duration":90000000000,"codec":"aac","sample_rate":44100,"channels":2}}}`,
+		},
 		{
 			desc: "src-specific fields: flickr",
 			meta: Meta{
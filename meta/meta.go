@@ -87,10 +87,22 @@ func (m *Meta) Image() Image {
 	return m.Inherent.Image
 }
 
+// Video returns the inherent video data.
+func (m *Meta) Video() Video {
+	return m.Inherent.Video
+}
+
+// Audio returns the inherent audio data.
+func (m *Meta) Audio() Audio {
+	return m.Inherent.Audio
+}
+
 // Content contains all data that describes the content directly.
 type Content struct {
 	Created time.Time
 	Image   Image
+	Video   Video
+	Audio   Audio
 	Exif    Exif
 }
 
@@ -100,6 +112,23 @@ type Image struct {
 	Height int `json:"height"`
 }
 
+// Video contains standard fields for all videos. The dimensions of a video
+// are stored in Image.
+type Video struct {
+	Duration  time.Duration `json:"duration,omitempty"`
+	Codec     string        `json:"codec,omitempty"`
+	FrameRate float64       `json:"frame_rate,omitempty"`
+}
+
+// Audio contains standard fields for all audio, including the audio track of
+// a video.
+type Audio struct {
+	Duration   time.Duration `json:"duration,omitempty"`
+	Codec      string        `json:"codec,omitempty"`
+	SampleRate int           `json:"sample_rate,omitempty"`
+	Channels   int           `json:"channels,omitempty"`
+}
+
 // SrcSpecific contains source-specific metadata.
 type SrcSpecific struct {
 	Flickr *FlickrMedia `json:"flickr,omitempty"`
@@ -108,9 +137,19 @@ type SrcSpecific struct {
 func (m Content) isZero() bool {
 	return m.Created.IsZero() &&
 		m.Image.isZero() &&
+		m.Video.isZero() &&
+		m.Audio.isZero() &&
 		len(m.Exif) == 0
 }
 
 func (m Image) isZero() bool {
 	return m.Width == 0 && m.Height == 0
 }
+
+func (m Video) isZero() bool {
+	return m == Video{}
+}
+
+func (m Audio) isZero() bool {
+	return m == Audio{}
+}
@@ -71,3 +71,18 @@ func TestMetaImage(t *testing.T) {
 		}
 	}
 }
+
+func TestMetaVideoAudio(t *testing.T) {
+	m := &Meta{
+		Inherent: Content{
+			Video: Video{Codec: "h264"},
+			Audio: Audio{Codec: "aac"},
+		},
+	}
+	if got, want := m.Video(), (Video{Codec: "h264"}); got != want {
+		t.Errorf("Meta.Video()\ngot  %#v\nwant %#v", got, want)
+	}
+	if got, want := m.Audio(), (Audio{Codec: "aac"}); got != want {
+		t.Errorf("Meta.Audio()\ngot  %#v\nwant %#v", got, want)
+	}
+}